@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ollama/ollama/api"
+)
+
+// What we keep in the history. The embedded api.Message keeps
+// the saved json compatible with the plain []api.Message we
+// used to store, everything else lives in messageMeta.
+type chatMessage struct {
+	api.Message
+	messageMeta
+}
+
+type messageMeta struct {
+	Server     string       `json:"server,omitempty"`     // who answered
+	Model      string       `json:"model,omitempty"`      // with what
+	Digest     string       `json:"digest,omitempty"`     // and which version of it
+	Sources    []citation   `json:"sources,omitempty"`    // knowledge base excerpts it got
+	Error      string       `json:"error,omitempty"`      // set when we gave up
+	Incomplete bool         `json:"incomplete,omitempty"` // cut off, can be continued
+	Starred    bool         `json:"starred,omitempty"`    // marked by the user
+	Stats      *api.Metrics `json:"stats,omitempty"`      // of the last generation
+	Status     string       `json:"-"`                    // retry countdown and such
+	ID         int          `json:"-"`                    // see messageID
+}
+
+// api.Message has its own UnmarshalJSON which would get promoted
+// and swallow our extra fields, so we decode twice.
+func (m *chatMessage) UnmarshalJSON(b []byte) error {
+	err := json.Unmarshal(b, &m.Message)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, &m.messageMeta)
+}
+
+func toAPIMessages(msgs []chatMessage) []api.Message {
+	out := make([]api.Message, 0, len(msgs))
+	for _, m := range msgs {
+		if m.Content == "" && len(m.Images) == 0 && m.Role != "user" {
+			continue // placeholder of a failed or pending answer
+		}
+		out = append(out, m.Message)
+	}
+	return out
+}
+
+// a short line for the renderers, empty if there is nothing to say.
+// Who answered does not change when the user picks another server.
+func messageNote(m chatMessage) string {
+	if m.Server != "" {
+		return "answered by " + m.Server
+	}
+	return ""
+}
+
+// "llama3.2:3b" for the message header, empty for old history
+func modelLabel(m chatMessage) string {
+	if m.Role == "user" {
+		return ""
+	}
+	return m.Model
+}
+
+// The model of the last answer before index, "" if unknown
+func (g *gui) lastModelBefore(index int) string {
+	for i := min(index, len(g.messages)) - 1; i >= 0; i-- {
+		if g.messages[i].Role == "assistant" && g.messages[i].Model != "" {
+			return g.messages[i].Model
+		}
+	}
+	return ""
+}
+
+// Asks before a different model picks up the conversation, then calls next
+func (g *gui) confirmModelSwitch(index int, next func()) {
+	last := g.lastModelBefore(index)
+	if last == "" || last == g.model {
+		next()
+		return
+	}
+	dialog.ShowConfirm("Different model",
+		fmt.Sprintf("The last answer came from %s.\nContinue this chat with %s?", last, g.model),
+		func(ok bool) {
+			if ok {
+				next()
+			}
+		}, g.w)
+}
+
+// "[1] setup.md, part 2 (81%)  [2] ..."
+func sourcesText(cites []citation) string {
+	var parts []string
+	for i, c := range cites {
+		parts = append(parts, fmt.Sprintf("[%d] %s", i+1, c))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// what to show while there is no content yet
+func messagePlaceholder(m chatMessage) string {
+	if m.Status != "" {
+		return m.Status
+	}
+	return "Loading..."
+}
+
+// The error badge and the note below a message for the markdown renderer
+func (g *gui) markdownExtras(item *widget.RichText, index int, m chatMessage) {
+	if note := messageNote(m); note != "" {
+		item.AppendMarkdown("*" + note + "*")
+	}
+	if len(m.Sources) > 0 {
+		item.AppendMarkdown("*Sources:* " + sourcesText(m.Sources))
+	}
+	if m.Error != "" {
+		item.Segments = append(item.Segments,
+			&widget.TextSegment{Text: "Error: " + m.Error + "  ", Style: widget.RichTextStyle{
+				ColorName: theme.ColorNameError,
+				Inline:    true,
+				TextStyle: fyne.TextStyle{Bold: true},
+			}},
+			&widget.HyperlinkSegment{Text: "Retry", OnTapped: func() { g.retry(index) }},
+			&widget.TextSegment{Style: widget.RichTextStyleParagraph}, // ends the line
+		)
+	}
+	if m.Incomplete {
+		item.Segments = append(item.Segments,
+			&widget.TextSegment{Text: "Incomplete  ", Style: widget.RichTextStyle{
+				ColorName: theme.ColorNameWarning,
+				Inline:    true,
+				TextStyle: fyne.TextStyle{Italic: true},
+			}},
+			&widget.HyperlinkSegment{Text: "Continue", OnTapped: func() { g.continueAt(index) }},
+			&widget.TextSegment{Style: widget.RichTextStyleParagraph},
+		)
+	}
+}
+
+// Same as markdownExtras, but for the normal renderer
+func (g *gui) normalExtras(index int, m chatMessage) []fyne.CanvasObject {
+	var extras []fyne.CanvasObject
+	if note := messageNote(m); note != "" {
+		notelabel := widget.NewLabelWithStyle(note, fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+		notelabel.Importance = widget.LowImportance
+		extras = append(extras, notelabel)
+	}
+	if len(m.Sources) > 0 {
+		sources := widget.NewLabel("Sources: " + sourcesText(m.Sources))
+		sources.Importance = widget.LowImportance
+		sources.Wrapping = fyne.TextWrapWord
+		extras = append(extras, sources)
+	}
+	if m.Error != "" {
+		errlabel := widget.NewLabelWithStyle("Error: "+m.Error, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+		errlabel.Importance = widget.DangerImportance
+		errlabel.Wrapping = fyne.TextWrapWord
+		retry := widget.NewButtonWithIcon("Retry", theme.ViewRefreshIcon(), func() { g.retry(index) })
+		extras = append(extras, container.NewBorder(nil, nil, nil, retry, errlabel))
+	}
+	if m.Incomplete {
+		inclabel := widget.NewLabelWithStyle("Incomplete", fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+		inclabel.Importance = widget.WarningImportance
+		cont := widget.NewButtonWithIcon("Continue", theme.MediaPlayIcon(), func() { g.continueAt(index) })
+		extras = append(extras, container.NewBorder(nil, nil, nil, cont, inclabel))
+	}
+	return extras
+}
+
+// " ★" behind the header of starred messages
+func starMark(m chatMessage) string {
+	if m.Starred {
+		return " ★"
+	}
+	return ""
+}
+
+// Asks again for the answer at index after an error. What arrived
+// before the error stays and gets continued.
+func (g *gui) retry(index int) {
+	if g.stopGenerating != nil || index >= len(g.messages) {
+		return // still busy with something else
+	}
+	g.messages[index] = chatMessage{Message: api.Message{Role: "assistant", Content: g.messages[index].Content}}
+	g.generate(index)
+}
+
+// Throws the answer at index away and asks for a new one
+func (g *gui) regenerate(index int) {
+	if g.stopGenerating != nil || index >= len(g.messages) {
+		return // still busy with something else
+	}
+	g.messages[index] = chatMessage{Message: api.Message{Role: "assistant"}}
+	g.generate(index)
+}
+
+// Lets the model pick up where the answer at index stopped
+func (g *gui) continueAt(index int) {
+	if g.stopGenerating != nil || index >= len(g.messages) {
+		return // still busy with something else
+	}
+	g.confirmModelSwitch(index+1, func() { g.generate(index) })
+}
+
+// Streams the answer for everything before index into g.messages[index].
+// If there already is a partial answer at index, it is sent along as the
+// last turn and the model continues it. Transient errors are retried with
+// a backoff, and if the active server does not want to, we ask the
+// failover servers.
+func (g *gui) generate(index int) {
+	// grey out during response, the send button turns into a stop button
+	g.usermessage.Disable()
+	sendbutton := g.usermessage.ActionItem.(*widget.Button)
+	sendbutton.SetIcon(theme.MediaStopIcon())
+	clientCTX, cancel := context.WithCancel(context.Background())
+	g.stopGenerating = cancel
+
+	// what we already have, an empty placeholder is not sent
+	base := g.messages[index]
+	base.Role = "assistant"
+	base.messageMeta = messageMeta{ID: base.ID} // open menus still mean this one
+
+	req := &api.ChatRequest{
+		Model:    g.model,
+		Messages: g.conversation.messages(g.messages[:index+1]),
+		Options:  g.conversation.options(),
+		// the following is there for user experience
+		// techically the server should set the
+		// "OLLAMA_KEEP_ALIVE=30min" environment variable
+		KeepAlive: &api.Duration{Duration: g.keepAlive()},
+	}
+	g.msgscroller.GoToBottom()
+
+	// the goroutine must not touch gui state
+	primary := g.client
+	primaryhost := g.lastserver
+	servers := slices.Clone(g.servers)
+	knowledge := g.knowledgeQuery()
+	primarydigest := g.modelinfo[g.model].Digest
+
+	msgflow := make(chan chatMessage)
+	go func() {
+		defer close(msgflow)
+
+		var cites []citation
+		if knowledge != nil {
+			searching := base
+			searching.Status = "Searching your documents..."
+			msgflow <- searching
+			msgs, c, err := knowledge.augment(clientCTX, primary, req.Messages)
+			if err != nil {
+				// answering without is better than not answering
+				fmt.Printf("knowledge base: %s\n", err)
+			}
+			req.Messages, cites = msgs, c
+		}
+
+		var msg chatMessage
+		tried := map[string]bool{primaryhost: true}
+		client, host, digest := primary, primaryhost, primarydigest
+		attempt := 0
+		for {
+			msg = base
+			msg.Server = host
+			msg.Model, msg.Digest = req.Model, digest
+			msg.Sources = cites
+			respFunc := func(resp api.ChatResponse) error {
+				msg.Content += resp.Message.Content
+				// ran into num_predict or the context size
+				msg.Incomplete = resp.Done && resp.DoneReason == "length"
+				if resp.Done {
+					msg.Stats = &resp.Metrics
+				}
+				msgflow <- msg
+				return nil
+			}
+
+			err := client.Chat(clientCTX, req, respFunc)
+			if err == nil {
+				if msg.Incomplete {
+					msgflow <- msg
+				}
+				return
+			}
+			if errors.Is(err, context.Canceled) {
+				// the user stopped us, keep what we got
+				msg.Incomplete = msg.Content != ""
+				if !msg.Incomplete {
+					msg.Error = "cancelled"
+				}
+				msgflow <- msg
+				return
+			}
+			kind := classifyError(err)
+			if !kind.retryable() {
+				msg.Error = fmt.Sprintf("%s: %s", kind, err)
+				msg.Incomplete = msg.Content != ""
+				msgflow <- msg
+				return
+			}
+
+			// another healthy server beats waiting for this one
+			next, nextclient, nextdigest, ferr := findFailover(clientCTX, servers, tried, req.Model, primarydigest)
+			if ferr == nil {
+				tried[next.Host] = true
+				client, host, digest = nextclient, next.Host, nextdigest
+				continue
+			}
+
+			if attempt >= maxRetries {
+				msg.Error = fmt.Sprintf("%s: %s (gave up after %d retries)", kind, err, attempt)
+				msg.Incomplete = msg.Content != ""
+				msgflow <- msg
+				return
+			}
+
+			// count down so the user knows we are not stuck
+			wait := retryBackoff(attempt)
+			attempt++
+			for left := wait; left > 0; left -= time.Second {
+				waiting := base
+				waiting.Status = fmt.Sprintf("%s, retry %d/%d in %ds...", kind, attempt, maxRetries, int(left.Seconds()))
+				msgflow <- waiting
+				select {
+				case <-time.After(time.Second):
+				case <-clientCTX.Done():
+					base.Incomplete = base.Content != ""
+					base.Error = "cancelled while waiting to retry"
+					msgflow <- base
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		// mainloop
+		for msg := range msgflow {
+			fyne.DoAndWait(func() {
+				g.messages[index] = msg
+
+				if !g.msgscroller.GoToBottomIfAtBottom() {
+					// we still need to refresh even
+					// if we dont scroll to bottom
+					g.msgscroller.RefreshCurrent()
+				}
+			})
+		}
+
+		// cleanup
+		fyne.DoAndWait(func() {
+			cancel()
+			g.stopGenerating = nil
+			// the prompt is in the history now, errors
+			// are retried from there
+			g.usermessage.SetText("")
+			sendbutton.SetIcon(theme.MailSendIcon())
+			g.usermessage.Enable()
+			g.indexHistory()
+			if isMobile {
+				// for mobile softkeyboard and resizing and
+				// scrolling reasons. see setFocusGainedCallback
+				g.w.Canvas().Unfocus()
+			}
+		})
+	}()
+}
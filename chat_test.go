@@ -0,0 +1,36 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestToAPIMessages(t *testing.T) {
+	user := func(s string) chatMessage { return chatMessage{Message: api.Message{Role: "user", Content: s}} }
+	assistant := func(s string) chatMessage { return chatMessage{Message: api.Message{Role: "assistant", Content: s}} }
+
+	for _, tc := range []struct {
+		name string
+		in   []chatMessage
+		want []string // role: content
+	}{
+		{"empty", nil, nil},
+		{"pending answer", []chatMessage{user("hi"), assistant("")}, []string{"user: hi"}},
+		// what old versions put in the history while waiting
+		{"role-less placeholder", []chatMessage{user("hi"), {}}, []string{"user: hi"}},
+		{"failed answer in between", []chatMessage{user("a"), assistant(""), user("b"), assistant("c")},
+			[]string{"user: a", "user: b", "assistant: c"}},
+		{"partial answer is continued", []chatMessage{user("hi"), assistant("Hel")}, []string{"user: hi", "assistant: Hel"}},
+		{"image without text", []chatMessage{{Message: api.Message{Role: "user", Images: []api.ImageData{{1}}}}}, []string{"user: "}},
+	} {
+		var got []string
+		for _, m := range toAPIMessages(tc.in) {
+			got = append(got, m.Role+": "+m.Content)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+type errorKind int
+
+const (
+	errorOther      errorKind = iota
+	errorConnection           // reset, refused, cut off mid stream
+	errorTimeout
+	errorServer  // 5xx
+	errorLoading // the model is still being loaded
+	errorClient  // 4xx, asking again wont help
+)
+
+func (k errorKind) String() string {
+	switch k {
+	case errorConnection:
+		return "connection lost"
+	case errorTimeout:
+		return "timeout"
+	case errorServer:
+		return "server error"
+	case errorLoading:
+		return "model loading"
+	case errorClient:
+		return "request error"
+	default:
+		return "error"
+	}
+}
+
+func (k errorKind) retryable() bool {
+	switch k {
+	case errorConnection, errorTimeout, errorServer, errorLoading:
+		return true
+	default:
+		return false
+	}
+}
+
+// Sorts an error from client.Chat into something we can act on.
+// Errors that arrive inside the stream are plain strings, so for
+// those we have to look at the text.
+func classifyError(err error) errorKind {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return errorOther
+	}
+
+	var serr api.StatusError
+	if errors.As(err, &serr) {
+		switch {
+		case serr.StatusCode >= http.StatusInternalServerError:
+			if isLoadingMessage(serr.ErrorMessage) {
+				return errorLoading
+			}
+			return errorServer
+		case serr.StatusCode >= http.StatusBadRequest:
+			return errorClient
+		}
+	}
+
+	var nerr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) ||
+		(errors.As(err, &nerr) && nerr.Timeout()) {
+		return errorTimeout
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return errorConnection
+	}
+	var operr *net.OpError
+	if errors.As(err, &operr) {
+		return errorConnection
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case isLoadingMessage(msg):
+		return errorLoading
+	case strings.Contains(msg, "timed out"):
+		return errorTimeout
+	case strings.Contains(msg, "busy"), strings.Contains(msg, "runner process has terminated"):
+		return errorServer
+	case strings.Contains(msg, "not found"), strings.Contains(msg, "invalid"):
+		return errorClient
+	}
+	return errorOther
+}
+
+func isLoadingMessage(s string) bool {
+	s = strings.ToLower(s)
+	return strings.Contains(s, "loading model") || strings.Contains(s, "model is loading")
+}
+
+const maxRetries = 4
+
+// longest wait between two tries, however many there are
+const maxBackoff = 30 * time.Second
+
+// 1s, 2s, 4s, 8s, ... up to maxBackoff
+func retryBackoff(attempt int) time.Duration {
+	d := time.Second
+	for range attempt {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
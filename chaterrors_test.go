@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestClassifyError(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want errorKind
+	}{
+		{nil, errorOther},
+		{context.Canceled, errorOther},
+		{api.StatusError{StatusCode: 500, ErrorMessage: "boom"}, errorServer},
+		{api.StatusError{StatusCode: 503, ErrorMessage: "loading model"}, errorLoading},
+		{api.StatusError{StatusCode: 404, ErrorMessage: "model not found"}, errorClient},
+		{context.DeadlineExceeded, errorTimeout},
+		{fmt.Errorf("read: %w", os.ErrDeadlineExceeded), errorTimeout},
+		{fmt.Errorf("post: %w", syscall.ECONNREFUSED), errorConnection},
+		{io.ErrUnexpectedEOF, errorConnection},
+		{&net.OpError{Op: "dial", Err: errors.New("no route to host")}, errorConnection},
+		// errors inside the stream are only text
+		{errors.New("model is loading, try again"), errorLoading},
+		{errors.New("request timed out"), errorTimeout},
+		{errors.New("server busy"), errorServer},
+		{errors.New("invalid options"), errorClient},
+		{errors.New("something else"), errorOther},
+	} {
+		if got := classifyError(tc.err); got != tc.want {
+			t.Errorf("%v: got %s, want %s", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, maxBackoff, maxBackoff} {
+		if got := retryBackoff(attempt); got != want {
+			t.Errorf("attempt %d: got %s, want %s", attempt, got, want)
+		}
+	}
+	// would overflow a plain shift
+	if got := retryBackoff(100); got != maxBackoff {
+		t.Errorf("attempt 100: got %s, want %s", got, maxBackoff)
+	}
+}
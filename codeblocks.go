@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// A piece of a message, either markdown prose or a fenced code block
+type messagePart struct {
+	text string
+	code bool
+	lang string
+}
+
+// Cuts the fenced code blocks out of markdown. A fence that is not
+// closed yet, because the answer is still streaming, runs to the end.
+func splitCodeFences(md string) []messagePart {
+	var parts []messagePart
+	var prose, code []string
+	var fence, lang string
+	flush := func() {
+		if len(prose) > 0 {
+			parts = append(parts, messagePart{text: strings.Join(prose, "\n")})
+			prose = nil
+		}
+	}
+	for _, line := range strings.Split(md, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		if fence == "" {
+			if indent <= 3 && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+				flush()
+				n := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+				fence = trimmed[:n]
+				lang, _, _ = strings.Cut(strings.TrimSpace(trimmed[n:]), " ")
+				code = nil
+				continue
+			}
+			prose = append(prose, line)
+			continue
+		}
+		if indent <= 3 && strings.HasPrefix(trimmed, fence) && strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) == "" {
+			parts = append(parts, messagePart{text: strings.Join(code, "\n"), code: true, lang: lang})
+			fence = ""
+			continue
+		}
+		code = append(code, line)
+	}
+	if fence != "" {
+		parts = append(parts, messagePart{text: strings.Join(code, "\n"), code: true, lang: lang})
+	}
+	flush()
+	return parts
+}
+
+// file extensions for "Save as file..."
+var langExtensions = map[string]string{
+	"go": ".go", "python": ".py", "javascript": ".js", "rust": ".rs", "c": ".c", "cpp": ".cpp",
+	"java": ".java", "shell": ".sh", "sql": ".sql", "json": ".json", "yaml": ".yaml", "yml": ".yaml",
+	"toml": ".toml", "ts": ".ts", "typescript": ".ts", "html": ".html", "css": ".css", "markdown": ".md", "md": ".md",
+}
+
+func snippetName(lang string) string {
+	lang = strings.ToLower(lang)
+	if ext, ok := langExtensions[lang]; ok {
+		return "snippet" + ext
+	}
+	if alias, ok := langAliases[lang]; ok {
+		if ext, ok := langExtensions[alias]; ok {
+			return "snippet" + ext
+		}
+	}
+	return "snippet.txt"
+}
+
+// A highlighted code block with the language on top and its own
+// Copy and Save buttons. Long lines scroll instead of wrapping.
+func (g *gui) codeBlock(code, lang string) fyne.CanvasObject {
+	shown := strings.ReplaceAll(code, "\t", "    ")
+	text := widget.NewRichText(highlightSegments(shown, lang)...)
+	text.Wrapping = fyne.TextWrapOff
+
+	name := lang
+	if name == "" {
+		name = "text"
+	}
+	label := widget.NewLabelWithStyle(name, fyne.TextAlignLeading, fyne.TextStyle{Monospace: true})
+	label.Importance = widget.LowImportance
+
+	copybutton := widget.NewButtonWithIcon("Copy", theme.ContentCopyIcon(), func() {
+		g.a.Clipboard().SetContent(code)
+	})
+	copybutton.Importance = widget.LowImportance
+	save := widget.NewButtonWithIcon("Save as file...", theme.DocumentSaveIcon(), func() {
+		d := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, g.w)
+				return
+			}
+			if w == nil {
+				return // cancelled
+			}
+			defer w.Close()
+			_, err = w.Write([]byte(code))
+			if err != nil {
+				dialog.ShowError(err, g.w)
+			}
+		}, g.w)
+		d.SetFileName(snippetName(lang))
+		d.Show()
+	})
+	save.Importance = widget.LowImportance
+
+	bg := canvas.NewRectangle(theme.Color(theme.ColorNameInputBackground))
+	bg.CornerRadius = theme.InputRadiusSize()
+	return container.NewStack(bg, container.NewBorder(
+		container.NewHBox(label, layout.NewSpacer(), copybutton, save),
+		nil, nil, nil,
+		container.NewHScroll(text),
+	))
+}
+
+// Appends md to item, but every fenced code block becomes a codeBlock
+// and the prose after it a new rich text, math included. Returns the objects that go
+// below item and the rich text the message ends with.
+func (g *gui) appendMarkdown(item *widget.RichText, md string) ([]fyne.CanvasObject, *widget.RichText) {
+	var more []fyne.CanvasObject
+	last := item
+	for i, p := range splitCodeFences(md) {
+		switch {
+		case p.code:
+			more = append(more, g.codeBlock(p.text, p.lang))
+			last = nil
+		case i == 0:
+			appendMathMarkdown(item, p.text)
+		default:
+			last = widget.NewRichText()
+			last.Wrapping = fyne.TextWrapWord
+			appendMathMarkdown(last, p.text)
+			more = append(more, last)
+		}
+	}
+	if last == nil {
+		// ended on code, the extras need a home below it
+		last = widget.NewRichText()
+		last.Wrapping = fyne.TextWrapWord
+		more = append(more, last)
+	}
+	return more, last
+}
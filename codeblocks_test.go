@@ -0,0 +1,34 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitCodeFences(t *testing.T) {
+	prose := func(s string) messagePart { return messagePart{text: s} }
+	code := func(lang, s string) messagePart { return messagePart{text: s, code: true, lang: lang} }
+
+	for _, tc := range []struct {
+		name string
+		in   string
+		want []messagePart
+	}{
+		{"empty", "", []messagePart{prose("")}},
+		{"prose only", "a\nb", []messagePart{prose("a\nb")}},
+		{"one block", "a\n```go\nx := 1\n```\nb", []messagePart{prose("a"), code("go", "x := 1"), prose("b")}},
+		{"info string after the language", "```python title=x.py\npass\n```", []messagePart{code("python", "pass")}},
+		{"tildes", "~~~\nx\n~~~", []messagePart{code("", "x")}},
+		{"still streaming", "a\n```sh\nls", []messagePart{prose("a"), code("sh", "ls")}},
+		{"shorter fence does not close", "````\n```\n````", []messagePart{code("", "```")}},
+		{"other fence does not close", "```\n~~~\n```", []messagePart{code("", "~~~")}},
+		{"indented fence", "   ```\nx\n   ```", []messagePart{code("", "x")}},
+		{"too far indented is no fence", "    ```\nx", []messagePart{prose("    ```\nx")}},
+		{"text after a closing fence", "```\nx\n```y\n```", []messagePart{code("", "x\n```y")}},
+		{"two blocks", "```a\n1\n```\n```b\n2\n```", []messagePart{code("a", "1"), code("b", "2")}},
+	} {
+		if got := splitCodeFences(tc.in); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ollama/ollama/api"
+)
+
+const (
+	minCompareColumns = 2
+	maxCompareColumns = 4
+)
+
+// "312 tokens, 42.1 tok/s, 7.4s total, 2.1s loading"
+func responseStats(m api.Metrics) string {
+	var parts []string
+	if m.EvalCount > 0 {
+		parts = append(parts, fmt.Sprintf("%d tokens", m.EvalCount))
+		if m.EvalDuration > 0 {
+			parts = append(parts, fmt.Sprintf("%.1f tok/s", float64(m.EvalCount)/m.EvalDuration.Seconds()))
+		}
+	}
+	if m.TotalDuration > 0 {
+		parts = append(parts, m.TotalDuration.Round(100*time.Millisecond).String()+" total")
+	}
+	if m.LoadDuration > 100*time.Millisecond {
+		parts = append(parts, m.LoadDuration.Round(100*time.Millisecond).String()+" loading")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// One model and its own history
+type compareColumn struct {
+	model   *widget.Select
+	history []chatMessage
+	text    *widget.RichText
+	scroll  *container.Scroll
+	stats   *widget.Label
+	promote *widget.Button
+}
+
+func (c *compareColumn) render() {
+	var sb strings.Builder
+	for _, m := range c.history {
+		switch {
+		case m.Role == "user":
+			sb.WriteString("**You:** " + m.Content + "\n\n")
+		case m.Error != "":
+			sb.WriteString(m.Content + "\n\n*Error: " + m.Error + "*\n\n")
+		case m.Content == "":
+			sb.WriteString("*" + messagePlaceholder(m) + "*\n\n")
+		default:
+			sb.WriteString(m.Content + "\n\n")
+		}
+		sb.WriteString("---\n\n")
+	}
+	c.text.ParseMarkdown(sb.String())
+	c.scroll.ScrollToBottom()
+}
+
+func (g *gui) compareWindow() {
+	w := g.a.NewWindow("Compare models")
+	client := g.client
+	host := g.lastserver
+	conv := g.conversation
+	keepalive := g.keepAlive()
+	var cancel context.CancelFunc
+
+	var names []string
+	digests := map[string]string{}
+	for name, m := range g.modelinfo {
+		names = append(names, name)
+		digests[name] = m.Digest
+	}
+	slices.Sort(names)
+
+	var columns []*compareColumn
+	grid := container.NewGridWithColumns(minCompareColumns)
+
+	prompt := widget.NewEntry()
+	prompt.PlaceHolder = "Ask all of them"
+	sequential := widget.NewCheck("One after another", nil)
+	var send, addcolumn, removecolumn *widget.Button
+
+	busy := func(b bool) {
+		for _, c := range columns {
+			if b {
+				c.model.Disable()
+				c.promote.Disable()
+			} else {
+				c.model.Enable()
+				c.promote.Enable()
+			}
+		}
+		if b {
+			send.SetIcon(theme.MediaStopIcon())
+			prompt.Disable()
+			addcolumn.Disable()
+			removecolumn.Disable()
+		} else {
+			send.SetIcon(theme.MailSendIcon())
+			prompt.Enable()
+			addcolumn.Enable()
+			removecolumn.Enable()
+			cancel = nil
+		}
+	}
+
+	relayout := func() {
+		grid.Objects = nil
+		for _, c := range columns {
+			grid.Add(container.NewBorder(
+				container.NewVBox(c.model, c.stats),
+				c.promote, nil, nil,
+				c.scroll,
+			))
+		}
+		grid.Layout = container.NewGridWithColumns(len(columns)).Layout
+		grid.Refresh()
+	}
+
+	// Streams the answer of one column, the ui is only touched via fyne.Do
+	answer := func(ctx context.Context, c *compareColumn, index int, req *api.ChatRequest) {
+		var content string
+		var metrics api.Metrics
+		err := client.Chat(ctx, req, func(resp api.ChatResponse) error {
+			content += resp.Message.Content
+			if resp.Done {
+				metrics = resp.Metrics
+			}
+			msg := chatMessage{Message: api.Message{Role: "assistant", Content: content}}
+			fyne.Do(func() {
+				c.history[index] = msg
+				c.render()
+			})
+			return nil
+		})
+		fyne.Do(func() {
+			msg := chatMessage{Message: api.Message{Role: "assistant", Content: content}}
+			msg.Server = host
+			msg.Model, msg.Digest = req.Model, digests[req.Model]
+			switch {
+			case errors.Is(err, context.Canceled):
+				msg.Incomplete = content != ""
+				if !msg.Incomplete {
+					msg.Error = "cancelled"
+				}
+			case err != nil:
+				msg.Error = fmt.Sprintf("%s: %s", classifyError(err), err)
+			default:
+				c.stats.SetText(responseStats(metrics))
+			}
+			c.history[index] = msg
+			c.render()
+		})
+	}
+
+	submit := func() {
+		if cancel != nil {
+			cancel()
+			return
+		}
+		text := strings.TrimSpace(prompt.Text)
+		if text == "" {
+			return
+		}
+		for _, c := range columns {
+			if c.model.Selected == "" {
+				dialog.ShowInformation("Compare models", "Pick a model for every column first.", w)
+				return
+			}
+		}
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		busy(true)
+
+		type job struct {
+			c     *compareColumn
+			index int
+			req   *api.ChatRequest
+		}
+		var jobs []job
+		for _, c := range columns {
+			c.history = append(c.history,
+				chatMessage{Message: api.Message{Role: "user", Content: text}},
+				chatMessage{Message: api.Message{Role: "assistant"}},
+			)
+			c.stats.SetText("")
+			c.render()
+			jobs = append(jobs, job{c, len(c.history) - 1, &api.ChatRequest{
+				Model:     c.model.Selected,
+				Messages:  conv.messages(c.history),
+				Options:   conv.options(),
+				KeepAlive: &api.Duration{Duration: keepalive},
+			}})
+		}
+		prompt.SetText("")
+
+		oneByOne := sequential.Checked
+		go func() {
+			if oneByOne {
+				for _, j := range jobs {
+					if ctx.Err() == nil {
+						answer(ctx, j.c, j.index, j.req)
+					}
+				}
+			} else {
+				var wg sync.WaitGroup
+				for _, j := range jobs {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						answer(ctx, j.c, j.index, j.req)
+					}()
+				}
+				wg.Wait()
+			}
+			fyne.Do(func() {
+				// skipped columns still have their placeholder
+				for _, j := range jobs {
+					last := &j.c.history[j.index]
+					if last.Content == "" && last.Error == "" {
+						last.Error = "cancelled"
+						j.c.render()
+					}
+				}
+				busy(false)
+			})
+		}()
+	}
+
+	newColumn := func() *compareColumn {
+		c := &compareColumn{
+			text:  widget.NewRichText(),
+			stats: widget.NewLabel(""),
+		}
+		c.text.Wrapping = fyne.TextWrapWord
+		c.scroll = container.NewVScroll(c.text)
+		c.stats.Importance = widget.LowImportance
+		c.stats.Truncation = fyne.TextTruncateEllipsis
+		c.model = widget.NewSelect(names, func(string) {
+			// the old answers came from somebody else
+			c.history = nil
+			c.stats.SetText("")
+			c.render()
+		})
+		c.model.PlaceHolder = "Pick a model"
+		c.promote = widget.NewButtonWithIcon("Continue in chat", theme.MailForwardIcon(), func() {
+			if len(c.history) == 0 {
+				return
+			}
+			if g.stopGenerating != nil {
+				dialog.ShowInformation("Compare models", "Wait for the chat to finish its answer first.", w)
+				return
+			}
+			dialog.ShowConfirm("Continue in chat", "This replaces the current chat with this column.\nThe current chat goes to the trash.", func(ok bool) {
+				if !ok {
+					return
+				}
+				old := g.messages
+				g.messages = slices.Clone(c.history)
+				if len(old) > 0 {
+					g.throwAway(trashEntry{messages: old, history: true})
+				}
+				g.modelselection.SetSelected(c.model.Selected)
+				g.msgscroller.GoToBottom()
+				w.Close()
+			}, w)
+		})
+		return c
+	}
+
+	for i := range minCompareColumns {
+		c := newColumn()
+		// start with what the user is chatting with
+		if i == 0 && slices.Contains(names, g.model) {
+			c.model.SetSelected(g.model)
+		}
+		columns = append(columns, c)
+	}
+
+	addcolumn = widget.NewButtonWithIcon("", theme.ContentAddIcon(), func() {
+		if len(columns) < maxCompareColumns {
+			columns = append(columns, newColumn())
+			relayout()
+		}
+	})
+	removecolumn = widget.NewButtonWithIcon("", theme.ContentRemoveIcon(), func() {
+		if len(columns) > minCompareColumns {
+			columns = columns[:len(columns)-1]
+			relayout()
+		}
+	})
+	prompt.OnSubmitted = func(string) { submit() }
+	send = widget.NewButtonWithIcon("", theme.MailSendIcon(), submit)
+	send.Importance = widget.HighImportance
+	clearall := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		if cancel != nil {
+			return
+		}
+		for _, c := range columns {
+			c.history = nil
+			c.stats.SetText("")
+			c.render()
+		}
+	})
+	relayout()
+
+	w.SetOnClosed(func() {
+		if cancel != nil {
+			cancel()
+		}
+	})
+	w.SetContent(container.NewBorder(
+		container.NewHBox(addcolumn, removecolumn, sequential, clearall),
+		container.NewBorder(nil, nil, nil, send, prompt),
+		nil, nil,
+		grid,
+	))
+	w.Resize(fyne.NewSize(1000, 700))
+	w.Show()
+}
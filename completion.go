@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ollama/ollama/api"
+)
+
+// The plain completion document, kept between restarts
+type completionState struct {
+	Document     string `json:"document"`
+	Raw          bool   `json:"raw,omitempty"`
+	Template     string `json:"template,omitempty"`
+	Suffix       string `json:"suffix,omitempty"`
+	CarryContext bool   `json:"carrycontext,omitempty"`
+	// the tokens of Seen as the server returned them, Seen is the
+	// document as it was then so edits to it can be told apart
+	Context []int  `json:"context,omitempty"`
+	Seen    string `json:"seen,omitempty"`
+}
+
+func (g *gui) loadCompletion() {
+	s := g.a.Preferences().String("completion")
+	if len(s) > 0 {
+		err := json.Unmarshal([]byte(s), &g.completion)
+		if err != nil {
+			g.addStartfunc(func() { dialog.ShowError(fmt.Errorf("error loading completion document: %w", err), g.w) })
+		}
+	}
+	g.addSavefunc(func() {
+		b, err := json.Marshal(g.completion)
+		if err != nil {
+			fmt.Printf("failed to save completion document: %s\n", err)
+			return
+		}
+		g.a.Preferences().SetString("completion", string(b))
+	})
+}
+
+// What to send: with a carried over context only the text after what
+// the server has already seen, otherwise the whole document. An edit
+// in the covered part makes the context worthless. Nothing new to send
+// would be an empty prompt, which ollama takes as "just load the model".
+func (cs completionState) prompt(doc string) (string, []int) {
+	if !cs.CarryContext || len(cs.Context) == 0 || cs.Seen == "" || !strings.HasPrefix(doc, cs.Seen) || doc == cs.Seen {
+		return doc, nil
+	}
+	return doc[len(cs.Seen):], cs.Context
+}
+
+func (g *gui) completionWindow() {
+	w := g.a.NewWindow("Completion")
+	cs := &g.completion
+	var cancel context.CancelFunc
+
+	document := widget.NewMultiLineEntry()
+	document.Wrapping = fyne.TextWrapWord
+	document.PlaceHolder = "Once upon a time"
+	document.SetText(cs.Document)
+	document.OnChanged = func(s string) { cs.Document = s }
+
+	raw := widget.NewCheck("Raw, no template", func(b bool) { cs.Raw = b })
+	raw.SetChecked(cs.Raw)
+	carry := widget.NewCheck("Carry over context", func(b bool) { cs.CarryContext = b })
+	carry.SetChecked(cs.CarryContext)
+
+	template := widget.NewMultiLineEntry()
+	template.TextStyle = fyne.TextStyle{Monospace: true}
+	template.PlaceHolder = "{{ .System }} {{ .Prompt }}"
+	template.SetText(cs.Template)
+	template.OnChanged = func(s string) { cs.Template = s }
+	template.SetMinRowsVisible(3)
+
+	suffix := widget.NewMultiLineEntry()
+	suffix.PlaceHolder = "Text after the gap, for fill in the middle"
+	suffix.SetText(cs.Suffix)
+	suffix.OnChanged = func(s string) { cs.Suffix = s }
+	suffix.SetMinRowsVisible(2)
+
+	stats := widget.NewLabel("")
+	stats.Importance = widget.LowImportance
+	stats.Wrapping = fyne.TextWrapWord
+
+	var generate *widget.Button
+	done := func() {
+		cancel = nil
+		generate.SetIcon(theme.MediaPlayIcon())
+		generate.SetText("Continue")
+		document.Enable()
+	}
+
+	generate = widget.NewButtonWithIcon("Continue", theme.MediaPlayIcon(), func() {
+		if cancel != nil {
+			cancel()
+			return
+		}
+		if g.model == "" || g.model == nomodel {
+			dialog.ShowInformation("Completion", "Pick a model first.", w)
+			return
+		}
+		prompt, tokens := cs.prompt(document.Text)
+		req := &api.GenerateRequest{
+			Model:     g.model,
+			Prompt:    prompt,
+			Suffix:    cs.Suffix,
+			Template:  cs.Template,
+			Raw:       cs.Raw,
+			Context:   tokens,
+			Options:   g.conversation.options(),
+			KeepAlive: &api.Duration{Duration: g.keepAlive()},
+		}
+		if !cs.Raw {
+			req.System = g.conversation.System
+		}
+		client := g.client
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		generate.SetIcon(theme.MediaStopIcon())
+		generate.SetText("Stop")
+		document.Disable() // the answer goes in at the end
+		stats.SetText("Generating...")
+
+		go func() {
+			var final api.GenerateResponse
+			err := client.Generate(ctx, req, func(resp api.GenerateResponse) error {
+				if resp.Done {
+					final = resp
+				}
+				fyne.Do(func() { document.Append(resp.Response) })
+				return nil
+			})
+			fyne.Do(func() {
+				defer done()
+				switch {
+				case errors.Is(err, context.Canceled):
+					stats.SetText("Stopped")
+					cs.Context, cs.Seen = nil, "" // the server did not tell us where it stopped
+				case err != nil:
+					stats.SetText(fmt.Sprintf("%s: %s", classifyError(err), err))
+				default:
+					text := responseStats(final.Metrics)
+					if final.DoneReason != "" && final.DoneReason != "stop" {
+						text += ", stopped by " + final.DoneReason
+					}
+					stats.SetText(text)
+					if len(final.Context) > 0 {
+						cs.Context, cs.Seen = final.Context, document.Text
+					}
+				}
+			})
+		}()
+	})
+	generate.Importance = widget.HighImportance
+
+	clearall := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+		if cancel != nil {
+			return
+		}
+		document.SetText("")
+		cs.Context, cs.Seen = nil, ""
+		stats.SetText("")
+	})
+
+	options := widget.NewAccordion(
+		widget.NewAccordionItem("Template and suffix", widget.NewForm(
+			widget.NewFormItem("Template", template),
+			widget.NewFormItem("Suffix", suffix),
+		)),
+	)
+
+	w.SetOnClosed(func() {
+		if cancel != nil {
+			cancel()
+		}
+	})
+	w.SetContent(container.NewBorder(
+		container.NewVBox(container.NewHBox(raw, carry), options),
+		container.NewVBox(stats, container.NewBorder(nil, nil, nil, clearall, generate)),
+		nil, nil,
+		document,
+	))
+	w.Resize(fyne.NewSize(640, 640))
+	w.Show()
+}
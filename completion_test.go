@@ -0,0 +1,31 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompletionPrompt(t *testing.T) {
+	carried := completionState{CarryContext: true, Context: []int{1, 2, 3}, Seen: "Once upon"}
+	for _, tc := range []struct {
+		name    string
+		cs      completionState
+		doc     string
+		prompt  string
+		context []int
+	}{
+		{"no context yet", completionState{CarryContext: true}, "Once upon", "Once upon", nil},
+		{"carrying is off", completionState{Context: []int{1}, Seen: "Once"}, "Once upon", "Once upon", nil},
+		{"only the new text", carried, "Once upon a time", " a time", []int{1, 2, 3}},
+		// an empty prompt would only load the model
+		{"nothing new", carried, "Once upon", "Once upon", nil},
+		{"edited before the end", carried, "Twice upon a time", "Twice upon a time", nil},
+		{"shortened", carried, "Once", "Once", nil},
+		{"old state without seen text", completionState{CarryContext: true, Context: []int{1}}, "Once", "Once", nil},
+	} {
+		prompt, context := tc.cs.prompt(tc.doc)
+		if prompt != tc.prompt || !slices.Equal(context, tc.context) {
+			t.Errorf("%s: got %q %v, want %q %v", tc.name, prompt, context, tc.prompt, tc.context)
+		}
+	}
+}
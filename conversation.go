@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ollama/ollama/api"
+)
+
+// Defaults for one model, applied when it gets picked
+type modelProfile struct {
+	System        string         `json:"system,omitempty"`
+	Parameters    string         `json:"parameters,omitempty"` // "name value" lines, like PARAMETER in a Modelfile
+	KeepAlive     *time.Duration `json:"keepalive,omitempty"`  // nil follows the server
+	StripThinking bool           `json:"stripthinking,omitempty"`
+}
+
+// Sent along with every request of the current chat. Follows the
+// profile of the picked model unless the user pinned it to this chat.
+type conversationSettings struct {
+	modelProfile
+	Override bool `json:"override,omitempty"`
+}
+
+func (g *gui) loadConversation() {
+	s := g.a.Preferences().String("conversation")
+	if len(s) > 0 {
+		err := json.Unmarshal([]byte(s), &g.conversation)
+		if err != nil {
+			g.addStartfunc(func() { dialog.ShowError(fmt.Errorf("error loading conversation settings: %w", err), g.w) })
+		}
+	}
+	g.profiles = map[string]modelProfile{}
+	s = g.a.Preferences().String("modelprofiles")
+	if len(s) > 0 {
+		err := json.Unmarshal([]byte(s), &g.profiles)
+		if err != nil {
+			g.addStartfunc(func() { dialog.ShowError(fmt.Errorf("error loading model profiles: %w", err), g.w) })
+		}
+	}
+	g.addSavefunc(func() {
+		b, err := json.Marshal(g.conversation)
+		if err != nil {
+			fmt.Printf("failed to save conversation settings: %s\n", err)
+			return
+		}
+		g.a.Preferences().SetString("conversation", string(b))
+		b, err = json.Marshal(g.profiles)
+		if err != nil {
+			fmt.Printf("failed to save model profiles: %s\n", err)
+			return
+		}
+		g.a.Preferences().SetString("modelprofiles", string(b))
+	})
+}
+
+// Called when the user picks a model, the chat follows its profile.
+// Without a profile the chat keeps what the user set.
+func (g *gui) selectModel(model string) {
+	changed := model != g.model
+	g.model = model
+	if p, ok := g.profiles[model]; ok && changed && !g.conversation.Override {
+		g.conversation.modelProfile = p
+	}
+	if changed {
+		g.warmup()
+	}
+}
+
+// <think>...</think> in front of an answer
+func stripThinking(s string) string {
+	rest, found := strings.CutPrefix(s, "<think>")
+	if !found {
+		return s
+	}
+	_, answer, found := strings.Cut(rest, "</think>")
+	if !found {
+		return s // never finished thinking, better than nothing
+	}
+	return strings.TrimSpace(answer)
+}
+
+// The system prompt goes in front, the history follows
+func (conv conversationSettings) messages(history []chatMessage) []api.Message {
+	msgs := toAPIMessages(history)
+	if conv.StripThinking {
+		for i := range msgs {
+			if msgs[i].Role == "assistant" {
+				msgs[i].Content = stripThinking(msgs[i].Content)
+			}
+		}
+	}
+	if conv.System == "" {
+		return msgs
+	}
+	return append([]api.Message{{Role: "system", Content: conv.System}}, msgs...)
+}
+
+// validated on save, so an error here means someone edited the prefs
+func (conv conversationSettings) options() map[string]any {
+	opts, err := parseParameters(conv.Parameters)
+	if err != nil {
+		fmt.Printf("ignoring broken parameters: %s\n", err)
+		return nil
+	}
+	return opts
+}
+
+func (g *gui) conversationWidget() fyne.CanvasObject {
+	return widget.NewButton("Conversation", func() {
+		w := g.a.NewWindow("Conversation")
+
+		system := widget.NewMultiLineEntry()
+		system.PlaceHolder = "You are a helpful assistant."
+		system.Wrapping = fyne.TextWrapWord
+		system.SetMinRowsVisible(5)
+
+		params := widget.NewMultiLineEntry()
+		params.PlaceHolder = "temperature 0.7\nnum_ctx 8192"
+		params.TextStyle = fyne.TextStyle{Monospace: true}
+		params.Validator = func(s string) error { _, err := parseParameters(s); return err }
+		params.SetMinRowsVisible(5)
+
+		keepalive := widget.NewSelect(keepAliveNames(), nil)
+		strip := widget.NewCheck("Leave old thinking out of the history", nil)
+		override := widget.NewCheck("Keep for this chat, ignore model profiles", nil)
+
+		show := func(p modelProfile) {
+			system.SetText(p.System)
+			params.SetText(p.Parameters)
+			keepalive.SetSelected(keepAliveName(p.KeepAlive))
+			strip.SetChecked(p.StripThinking)
+		}
+		show(g.conversation.modelProfile)
+		override.SetChecked(g.conversation.Override)
+
+		current := func() conversationSettings {
+			return conversationSettings{
+				modelProfile: modelProfile{
+					System:        system.Text,
+					Parameters:    params.Text,
+					KeepAlive:     keepAliveFromName(keepalive.Selected),
+					StripThinking: strip.Checked,
+				},
+				Override: override.Checked,
+			}
+		}
+
+		form := widget.NewForm(
+			widget.NewFormItem("System prompt", system),
+			widget.NewFormItem("Parameters", params),
+			widget.NewFormItem("Keep loaded", keepalive),
+			widget.NewFormItem("", strip),
+			widget.NewFormItem("", override),
+		)
+		form.SubmitText = "Save"
+		form.OnSubmit = func() {
+			g.conversation = current()
+			w.Close()
+		}
+		form.OnCancel = func() { w.Close() }
+
+		model := g.model
+		asprofile := widget.NewButton("Use as default for "+model, func() {
+			if params.Validate() != nil {
+				return
+			}
+			g.profiles[model] = current().modelProfile
+			dialog.ShowInformation("Model profile", "Picking "+model+" now brings these settings along.", w)
+		})
+		fromprofile := widget.NewButton("Reset to profile", func() { show(g.profiles[model]) })
+		asmodel := widget.NewButton("Save as model...", func() {
+			if params.Validate() != nil {
+				return
+			}
+			modelfile, err := conversationModelfile(model, current())
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			g.modelfileEditor(model+"-custom", modelfile)
+		})
+
+		w.SetContent(container.NewBorder(
+			widget.NewLabel("Used for every message of this chat"),
+			container.NewVBox(asprofile, container.NewGridWithColumns(2, fromprofile, asmodel)),
+			nil, nil,
+			container.NewVScroll(form),
+		))
+		w.Resize(fyne.NewSize(480, 560))
+		w.Show()
+	})
+}
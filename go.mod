@@ -11,6 +11,7 @@ require (
 	fyne.io/fyne/v2 v2.6.0
 	github.com/ollama/ollama v0.6.6
 	github.com/wlynxg/anet v0.0.5
+	golang.org/x/net v0.39.0
 )
 
 require (
@@ -41,7 +42,6 @@ require (
 	github.com/stretchr/testify v1.10.0 // indirect
 	github.com/yuin/goldmark v1.7.10 // indirect
 	golang.org/x/image v0.26.0 // indirect
-	golang.org/x/net v0.39.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
 	golang.org/x/text v0.24.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -6,6 +6,8 @@ import (
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/driver/desktop"
 	"fyne.io/fyne/v2/driver/mobile"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
 
@@ -17,13 +19,18 @@ func (g *gui) goodEnoughDialog(title, s string) {
 	d.Show()
 }
 
-// Attach an arbitrary function to a primary and secondary Tap
+// Attach an arbitrary function to a primary and secondary Tap,
+// optionally with a "..." menu button that shows on hover
 type tappler struct {
 	widget.BaseWidget
 	child fyne.CanvasObject
 	cbPri func(*fyne.PointEvent)
 	cbSec func(*fyne.PointEvent)
 	cbDou func(*fyne.PointEvent)
+	//
+	menu       func() *fyne.Menu // built when opened, nil for no button
+	menubutton *hoverButton
+	hovered    bool
 }
 
 var _ fyne.Tappable = (*tappler)(nil)
@@ -56,8 +63,86 @@ func NewTapperLayer(child fyne.CanvasObject, cbPrimary func(*fyne.PointEvent), c
 	return t
 }
 
+// Must be called before the tappler is shown
+func (t *tappler) SetMenu(menu func() *fyne.Menu) {
+	t.menu = menu
+}
+
+func (t *tappler) ShowMenuAt(pos fyne.Position) {
+	c := fyne.CurrentApp().Driver().CanvasForObject(t)
+	if t.menu == nil || c == nil {
+		return
+	}
+	widget.ShowPopUpMenuAtPosition(t.menu(), c, pos)
+}
+
+var _ desktop.Hoverable = (*tappler)(nil)
+
+func (t *tappler) MouseIn(*desktop.MouseEvent) {
+	t.hovered = true
+	t.updateMenuButton()
+}
+
+func (t *tappler) MouseMoved(*desktop.MouseEvent) {}
+
+func (t *tappler) MouseOut() {
+	t.hovered = false
+	t.updateMenuButton()
+}
+
+// moving onto the button is a MouseOut for us, so it counts too
+func (t *tappler) updateMenuButton() {
+	if t.menubutton == nil {
+		return
+	}
+	if t.hovered || t.menubutton.hovered {
+		t.menubutton.Show()
+	} else {
+		t.menubutton.Hide()
+	}
+}
+
 func (t *tappler) CreateRenderer() fyne.WidgetRenderer {
-	return widget.NewSimpleRenderer(t.child)
+	if t.menu == nil {
+		return widget.NewSimpleRenderer(t.child)
+	}
+	t.menubutton = newHoverButton(theme.MoreHorizontalIcon(), t.updateMenuButton)
+	t.menubutton.OnTapped = func() {
+		pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(t.menubutton)
+		t.ShowMenuAt(pos.AddXY(0, t.menubutton.Size().Height))
+	}
+	t.menubutton.Importance = widget.LowImportance
+	t.menubutton.Hide()
+	return widget.NewSimpleRenderer(container.NewStack(
+		t.child,
+		container.NewVBox(container.NewHBox(layout.NewSpacer(), t.menubutton)),
+	))
+}
+
+// A button that tells when the mouse is over it
+type hoverButton struct {
+	widget.Button
+	hovered bool
+	changed func()
+}
+
+func newHoverButton(icon fyne.Resource, changed func()) *hoverButton {
+	b := &hoverButton{changed: changed}
+	b.Icon = icon
+	b.ExtendBaseWidget(b)
+	return b
+}
+
+func (b *hoverButton) MouseIn(e *desktop.MouseEvent) {
+	b.hovered = true
+	b.Button.MouseIn(e)
+	b.changed()
+}
+
+func (b *hoverButton) MouseOut() {
+	b.hovered = false
+	b.Button.MouseOut()
+	b.changed()
 }
 
 // Change the way widget.Entry works for this usecase
@@ -200,3 +285,30 @@ func (st *minSizer) CreateRenderer() fyne.WidgetRenderer {
 		sendbutton.SetMinSize(fyne.NewSquareSize(max(sz.Height, sz.Width)))
 	})
 */
+
+// The windows that dont need a button of their own in the top bar
+func (g *gui) toolsButton() fyne.CanvasObject {
+	var tools *widget.Button
+	menu := fyne.NewMenu("",
+		fyne.NewMenuItem("Pull model", g.pullWindow),
+		fyne.NewMenuItem("Manage models", g.modelManager),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Compare models", g.compareWindow),
+		fyne.NewMenuItem("Completion", g.completionWindow),
+		fyne.NewMenuItem("Semantic search", func() { g.semanticWindow(nil) }),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Trash", g.trashWindow),
+	)
+	menu.Items[0].Icon = theme.DownloadIcon()
+	menu.Items[1].Icon = theme.StorageIcon()
+	menu.Items[3].Icon = theme.GridIcon()
+	menu.Items[4].Icon = theme.DocumentIcon()
+	menu.Items[5].Icon = theme.SearchIcon()
+	menu.Items[7].Icon = theme.DeleteIcon()
+	tools = widget.NewButtonWithIcon("", theme.MoreVerticalIcon(), func() {
+		c := fyne.CurrentApp().Driver().CanvasForObject(tools)
+		pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(tools)
+		widget.ShowPopUpMenuAtPosition(menu, c, pos.AddXY(0, tools.Size().Height))
+	})
+	return tools
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// A tiny highlighter, it knows words, strings, numbers and comments.
+// Not a parser, but good enough to make code in answers readable.
+
+type tokenKind int
+
+const (
+	tokenPlain tokenKind = iota
+	tokenKeyword
+	tokenType
+	tokenString
+	tokenNumber
+	tokenComment
+)
+
+type token struct {
+	text string
+	kind tokenKind
+}
+
+type langSpec struct {
+	keywords     []string
+	types        []string
+	lineComments []string
+	blockComment [2]string
+	quotes       string // characters that start a string
+	multiline    string // quotes that may span lines
+	triple       bool   // python """ and '''
+}
+
+func words(s string) []string { return strings.Fields(s) }
+
+var (
+	cLike = langSpec{
+		keywords:     words("if else for while do switch case default break continue return goto struct union enum typedef sizeof static const extern volatile inline register"),
+		types:        words("void char short int long float double signed unsigned bool size_t uint8_t uint16_t uint32_t uint64_t int8_t int16_t int32_t int64_t NULL true false"),
+		lineComments: []string{"//"},
+		blockComment: [2]string{"/*", "*/"},
+		quotes:       `"'`,
+	}
+	langs = map[string]langSpec{
+		"go": {
+			keywords:     words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var"),
+			types:        words("bool byte complex64 complex128 error float32 float64 int int8 int16 int32 int64 rune string uint uint8 uint16 uint32 uint64 uintptr any true false nil iota append cap close copy delete len make new panic print println recover min max clear"),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       "\"'`",
+			multiline:    "`",
+		},
+		"python": {
+			keywords:     words("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield match case"),
+			types:        words("True False None self int float str bool list dict set tuple bytes object print len range open super isinstance enumerate zip map filter"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+			triple:       true,
+		},
+		"javascript": {
+			keywords:     words("async await break case catch class const continue debugger default delete do else export extends finally for from function if import in instanceof let new of return static super switch this throw try typeof var void while with yield interface type implements enum"),
+			types:        words("true false null undefined NaN Infinity string number boolean any unknown never object Array Object Promise Map Set console"),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       "\"'`",
+			multiline:    "`",
+		},
+		"rust": {
+			keywords:     words("as async await break const continue crate dyn else enum extern fn for if impl in let loop match mod move mut pub ref return static struct super trait type unsafe use where while"),
+			types:        words("Self self true false bool char str String i8 i16 i32 i64 i128 isize u8 u16 u32 u64 u128 usize f32 f64 Vec Option Some None Result Ok Err Box"),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `"`,
+		},
+		"c": cLike,
+		"cpp": {
+			keywords:     append(words("class namespace template typename public private protected virtual override new delete using try catch throw auto constexpr nullptr this operator"), cLike.keywords...),
+			types:        append(words("std string vector map"), cLike.types...),
+			lineComments: cLike.lineComments,
+			blockComment: cLike.blockComment,
+			quotes:       cLike.quotes,
+		},
+		"java": {
+			keywords:     words("abstract assert break case catch class continue default do else enum extends final finally for if implements import instanceof interface new package private protected public return static super switch synchronized this throw throws try volatile while var record"),
+			types:        words("boolean byte char double float int long short void String Integer Object List Map true false null"),
+			lineComments: []string{"//"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `"'`,
+		},
+		"shell": {
+			keywords:     words("if then else elif fi for in do done while until case esac function return local export source exit set"),
+			types:        words("echo cd ls cat grep sed awk sudo apt curl git make docker"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+			multiline:    `"'`,
+		},
+		"sql": {
+			keywords:     words("select from where insert into values update set delete create table drop alter add join left right inner outer on group by order having limit offset as and or not null is in like primary key foreign references index distinct union all case when then else end SELECT FROM WHERE INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER ADD JOIN LEFT RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT OFFSET AS AND OR NOT NULL IS IN LIKE PRIMARY KEY FOREIGN REFERENCES INDEX DISTINCT UNION ALL CASE WHEN THEN ELSE END"),
+			types:        words("int integer text varchar char boolean date timestamp real float serial INT INTEGER TEXT VARCHAR CHAR BOOLEAN DATE TIMESTAMP REAL FLOAT SERIAL"),
+			lineComments: []string{"--"},
+			blockComment: [2]string{"/*", "*/"},
+			quotes:       `'"`,
+		},
+		"data": { // json, yaml, toml
+			types:        words("true false null yes no"),
+			lineComments: []string{"#"},
+			quotes:       `"'`,
+		},
+	}
+	langAliases = map[string]string{
+		"golang": "go", "py": "python", "python3": "python",
+		"js": "javascript", "jsx": "javascript", "ts": "javascript", "tsx": "javascript", "typescript": "javascript", "node": "javascript",
+		"rs": "rust", "h": "c", "c++": "cpp", "cc": "cpp", "hpp": "cpp", "cs": "java", "csharp": "java", "kotlin": "java", "kt": "java",
+		"sh": "shell", "bash": "shell", "zsh": "shell", "console": "shell",
+		"json": "data", "yaml": "data", "yml": "data", "toml": "data", "ini": "data",
+		"postgres": "sql", "mysql": "sql", "sqlite": "sql",
+	}
+)
+
+func langFor(name string) (langSpec, bool) {
+	name = strings.ToLower(name)
+	if alias, ok := langAliases[name]; ok {
+		name = alias
+	}
+	spec, ok := langs[name]
+	return spec, ok
+}
+
+func isIdentStart(r rune) bool { return r == '_' || unicode.IsLetter(r) }
+func isIdentPart(r rune) bool  { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+// Splits code into tokens, unknown languages come back as one plain token
+func tokenize(code, lang string) []token {
+	spec, ok := langFor(lang)
+	if !ok {
+		return []token{{code, tokenPlain}}
+	}
+	keywords := map[string]bool{}
+	for _, k := range spec.keywords {
+		keywords[k] = true
+	}
+	types := map[string]bool{}
+	for _, t := range spec.types {
+		types[t] = true
+	}
+
+	var tokens []token
+	emit := func(text string, kind tokenKind) {
+		if n := len(tokens); n > 0 && tokens[n-1].kind == kind {
+			tokens[n-1].text += text
+			return
+		}
+		tokens = append(tokens, token{text, kind})
+	}
+	// up to and including end, or everything if it never comes
+	until := func(rest, end string, from int) int {
+		i := strings.Index(rest[from:], end)
+		if i < 0 {
+			return len(rest)
+		}
+		return from + i + len(end)
+	}
+
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		r, size := utf8.DecodeRuneInString(rest)
+		n := 0
+		kind := tokenPlain
+
+		switch {
+		case spec.blockComment[0] != "" && strings.HasPrefix(rest, spec.blockComment[0]):
+			n, kind = until(rest, spec.blockComment[1], len(spec.blockComment[0])), tokenComment
+		case hasAnyPrefix(rest, spec.lineComments):
+			n, kind = until(rest, "\n", 0), tokenComment
+			if n > 0 && rest[n-1] == '\n' {
+				n-- // the newline is not part of the comment
+			}
+		case spec.triple && (strings.HasPrefix(rest, `"""`) || strings.HasPrefix(rest, `'''`)):
+			n, kind = until(rest, rest[:3], 3), tokenString
+		case strings.ContainsRune(spec.quotes, r):
+			n, kind = stringEnd(rest, r, strings.ContainsRune(spec.multiline, r)), tokenString
+		case unicode.IsDigit(r):
+			n = strings.IndexFunc(rest, func(c rune) bool { return !isIdentPart(c) && c != '.' })
+			if n < 0 {
+				n = len(rest)
+			}
+			kind = tokenNumber
+		case isIdentStart(r):
+			n = strings.IndexFunc(rest, func(c rune) bool { return !isIdentPart(c) })
+			if n < 0 {
+				n = len(rest)
+			}
+			switch word := rest[:n]; {
+			case keywords[word]:
+				kind = tokenKeyword
+			case types[word]:
+				kind = tokenType
+			}
+		default:
+			n = size
+		}
+		emit(rest[:n], kind)
+		i += n
+	}
+	return tokens
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// the length of the string literal at the start of s, escapes included
+func stringEnd(s string, quote rune, multiline bool) int {
+	escaped := false
+	for i, r := range s {
+		switch {
+		case i == 0:
+		case escaped:
+			escaped = false
+		case r == '\\' && quote != '`':
+			escaped = true
+		case r == quote:
+			return i + utf8.RuneLen(r)
+		case r == '\n' && !multiline:
+			return i // unterminated, stop at the line end
+		}
+	}
+	return len(s)
+}
+
+func (k tokenKind) color() fyne.ThemeColorName {
+	switch k {
+	case tokenKeyword:
+		return theme.ColorNamePrimary
+	case tokenType:
+		return theme.ColorNameHyperlink
+	case tokenString:
+		return theme.ColorNameSuccess
+	case tokenNumber:
+		return theme.ColorNameWarning
+	case tokenComment:
+		return theme.ColorNamePlaceHolder
+	default:
+		return theme.ColorNameForeground
+	}
+}
+
+// RichText segments, one line ends where a segment is not inline
+func highlightSegments(code, lang string) []widget.RichTextSegment {
+	var segs []widget.RichTextSegment
+	var line []*widget.TextSegment
+	endLine := func() {
+		if len(line) == 0 {
+			line = append(line, &widget.TextSegment{Text: " "}) // keep empty lines
+		}
+		for i, s := range line {
+			s.Style = widget.RichTextStyle{
+				ColorName: s.Style.ColorName,
+				Inline:    i < len(line)-1,
+				SizeName:  theme.SizeNameText,
+				TextStyle: fyne.TextStyle{Monospace: true},
+			}
+			segs = append(segs, s)
+		}
+		line = nil
+	}
+	for _, t := range tokenize(code, lang) {
+		parts := strings.Split(t.text, "\n")
+		for i, p := range parts {
+			if i > 0 {
+				endLine()
+			}
+			if p != "" {
+				line = append(line, &widget.TextSegment{Text: p, Style: widget.RichTextStyle{ColorName: t.kind.color()}})
+			}
+		}
+	}
+	endLine()
+	return segs
+}
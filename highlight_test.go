@@ -0,0 +1,74 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	kinds := map[tokenKind]string{tokenPlain: "", tokenKeyword: "kw", tokenType: "type", tokenString: "str", tokenNumber: "num", tokenComment: "com"}
+
+	for _, tc := range []struct {
+		name, lang, code string
+		want             []string // kind:text, plain tokens without the kind
+	}{
+		{"unknown language", "brainfuck", "if x", []string{"if x"}},
+		{"empty", "go", "", nil},
+		{"keywords and types", "go", "func f() int", []string{"kw:func", " f() ", "type:int"}},
+		{"alias and case", "Golang", "return", []string{"kw:return"}},
+		{"identifiers are not keywords", "go", "iffy", []string{"iffy"}},
+		{"numbers", "go", "x = 3.14", []string{"x = ", "num:3.14"}},
+		{"line comment stops at the newline", "go", "x // y\nz", []string{"x ", "com:// y", "\nz"}},
+		{"block comment", "c", "a /* b\nc */ d", []string{"a ", "com:/* b\nc */", " d"}},
+		{"unclosed block comment", "c", "/* a", []string{"com:/* a"}},
+		{"escaped quote", "go", `"a\"b" c`, []string{`str:"a\"b"`, " c"}},
+		{"raw string spans lines", "go", "`a\nb` c", []string{"str:`a\nb`", " c"}},
+		{"string stops at the line end", "go", "\"a\nb", []string{`str:"a`, "\nb"}},
+		{"python triple quotes", "py", "'''a\n'b'''", []string{"str:'''a\n'b'''"}},
+		{"python comment", "python", "# if", []string{"com:# if"}},
+	} {
+		var got []string
+		for _, tok := range tokenize(tc.code, tc.lang) {
+			if k := kinds[tok.kind]; k != "" {
+				got = append(got, k+":"+tok.text)
+			} else {
+				got = append(got, tok.text)
+			}
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+		// nothing gets lost or added
+		var sb strings.Builder
+		for _, tok := range tokenize(tc.code, tc.lang) {
+			sb.WriteString(tok.text)
+		}
+		if sb.String() != tc.code {
+			t.Errorf("%s: tokens give %q back, not %q", tc.name, sb.String(), tc.code)
+		}
+	}
+}
+
+func TestStringEnd(t *testing.T) {
+	for _, tc := range []struct {
+		s         string
+		quote     rune
+		multiline bool
+		want      int
+	}{
+		{`"a" b`, '"', false, 3},
+		{`"a\"b" c`, '"', false, 6},
+		{`"a\\" b`, '"', false, 5},
+		{"\"a\nb\"", '"', false, 2},
+		{"`a\nb`", '`', true, 5},
+		{"`a\\`", '`', true, 4}, // no escapes in raw strings
+		{`"ü" x`, '"', false, 4},
+		{`"never`, '"', false, 6},
+		{`'x'`, '\'', false, 3},
+	} {
+		if got := stringEnd(tc.s, tc.quote, tc.multiline); got != tc.want {
+			t.Errorf("%q: got %d, want %d", tc.s, got, tc.want)
+		}
+	}
+}
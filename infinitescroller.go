@@ -71,6 +71,7 @@ func (is *infiniteScroller) GoToSpecific(index int) {
 	is.ubound = is.lbound + is.maxobjs
 	is.ubound = min(is.ubound, is.lenobjs())         // when something is close to the bottom
 	is.lbound = min(is.lbound, is.ubound-is.maxobjs) // prevent lbound>ubound
+	is.lbound = max(0, is.lbound)                    // when there are fewer than maxobjs
 
 	// put the scrollbar into the center
 	is.scroll.ScrollToOffset(fyne.NewPos(0, (is.scroll.Content.Size().Height/2)-(is.scroll.Size().Height/2)))
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// What the user can pick for servers and model profiles, "Default"
+// on top means nil and hands the decision to the next level
+var keepAliveSettingChoices = append([]keepAliveChoice{{"Unload immediately", 0}}, keepLoadedChoices...)
+
+func keepAliveNames() []string {
+	names := []string{"Default"}
+	for _, c := range keepAliveSettingChoices {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// the select entry for d, "Default" for nil and anything we dont offer
+func keepAliveName(d *time.Duration) string {
+	if d == nil {
+		return "Default"
+	}
+	i := slices.IndexFunc(keepAliveSettingChoices, func(c keepAliveChoice) bool { return c.d == *d })
+	if i < 0 {
+		return "Default"
+	}
+	return keepAliveSettingChoices[i].name
+}
+
+func keepAliveFromName(name string) *time.Duration {
+	i := slices.IndexFunc(keepAliveSettingChoices, func(c keepAliveChoice) bool { return c.name == name })
+	if i < 0 {
+		return nil
+	}
+	d := keepAliveSettingChoices[i].d // a copy, profiles keep the pointer
+	return &d
+}
+
+// The chat (or model profile) wins over the server, the server over us
+func (g *gui) keepAlive() time.Duration {
+	if g.conversation.KeepAlive != nil {
+		return *g.conversation.KeepAlive
+	}
+	if sp := g.profileFor(g.lastserver); sp.KeepAlive != nil {
+		return *sp.KeepAlive
+	}
+	return defaultKeepAlive
+}
+
+func (g *gui) setModelStatus(s string, importance widget.Importance) {
+	g.modelstatus.Importance = importance
+	g.modelstatus.SetText(s)
+	if s == "" {
+		g.modelstatus.Hide()
+	} else {
+		g.modelstatus.Show()
+	}
+}
+
+// Loads the model before the first question so the answer comes quicker.
+// Shows how long it takes and complains when the server cant do it,
+// not enough memory being the usual reason.
+func (g *gui) warmup() {
+	if g.stopWarmup != nil {
+		g.stopWarmup()
+		g.stopWarmup = nil
+	}
+	model := g.model
+	keepalive := g.keepAlive()
+	if model == "" || model == nomodel || keepalive == 0 || g.lastserver == "" {
+		g.setModelStatus("", widget.LowImportance)
+		return // nothing to load or it would be thrown out right away
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g.stopWarmup = cancel
+	client := g.client
+	start := time.Now()
+	g.setModelStatus("Loading "+model+"...", widget.LowImportance)
+
+	go func() {
+		done := make(chan error, 1)
+		go func() { done <- setKeepAlive(ctx, client, model, keepalive) }()
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				elapsed := time.Since(start).Round(time.Second)
+				fyne.Do(func() {
+					if ctx.Err() == nil {
+						g.setModelStatus(fmt.Sprintf("Loading %s... %s", model, elapsed), widget.LowImportance)
+					}
+				})
+			case err := <-done:
+				fyne.Do(func() {
+					if ctx.Err() != nil {
+						return // another model took over
+					}
+					cancel()
+					g.stopWarmup = nil
+					if err != nil {
+						g.setModelStatus(fmt.Sprintf("Loading %s failed", model), widget.DangerImportance)
+						dialog.ShowError(fmt.Errorf("loading %s failed: %w", model, err), g.w)
+						return
+					}
+					g.setModelStatus("", widget.LowImportance)
+				})
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,19 @@
+package main
+
+import "testing"
+
+func TestKeepAliveNames(t *testing.T) {
+	for _, name := range keepAliveNames() {
+		if got := keepAliveName(keepAliveFromName(name)); got != name {
+			t.Errorf("%q came back as %q", name, got)
+		}
+	}
+
+	// what a profile does with its pointer must not change the choices
+	name := keepAliveSettingChoices[1].name
+	d := keepAliveFromName(name)
+	*d = 42
+	if keepAliveSettingChoices[1].d == 42 || keepAliveFromName(name) == d {
+		t.Error("keepAliveFromName hands out the choice table")
+	}
+}
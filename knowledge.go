@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ollama/ollama/api"
+)
+
+const (
+	knowledgeFile     = "knowledge.json"
+	chunkSize         = 1200 // characters, roughly 300 tokens
+	chunkOverlap      = 200
+	maxKnowledgeFile  = 1 << 20 // bigger files are probably not docs
+	defaultTopK       = 4
+	knowledgeMinScore = 0.3 // below that it is noise
+)
+
+// what we read, everything else is skipped when importing folders
+var knowledgeExtensions = []string{
+	".txt", ".md", ".markdown", ".rst", ".adoc", ".org",
+	".go", ".py", ".js", ".ts", ".jsx", ".tsx", ".rs", ".c", ".h", ".cpp", ".hpp", ".cs",
+	".java", ".kt", ".rb", ".php", ".swift", ".sh", ".sql", ".lua", ".zig",
+	".json", ".yaml", ".yml", ".toml", ".ini", ".xml", ".html", ".css", ".csv",
+}
+
+type knowledgeChunk struct {
+	Source string    `json:"source"` // uri of the file
+	Part   int       `json:"part"`   // 1 based
+	Text   string    `json:"text"`
+	Vector []float32 `json:"vector"`
+}
+
+type knowledgeSource struct {
+	URI   string    `json:"uri"`
+	Name  string    `json:"name"`
+	Parts int       `json:"parts"`
+	Added time.Time `json:"added"`
+}
+
+// Documents the user attached, chunked and embedded
+type knowledgeBase struct {
+	Enabled bool              `json:"enabled"`
+	Model   string            `json:"model"` // embedding model
+	TopK    int               `json:"topk"`
+	Sources []knowledgeSource `json:"sources,omitempty"`
+	Chunks  []knowledgeChunk  `json:"chunks,omitempty"`
+}
+
+// What an answer was built on, shown below it
+type citation struct {
+	Source string  `json:"source"` // file name
+	Part   int     `json:"part"`
+	Score  float64 `json:"score"`
+}
+
+func (c citation) String() string {
+	return fmt.Sprintf("%s, part %d (%.0f%%)", c.Source, c.Part, c.Score*100)
+}
+
+// Cuts text into overlapping pieces, preferring paragraph and line breaks
+func chunkText(text string) []string {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	var chunks []string
+	for len(text) > 0 {
+		if utf8.RuneCountInString(text) <= chunkSize {
+			chunks = append(chunks, text)
+			break
+		}
+		// byte offset of chunkSize runes
+		end := 0
+		for i := 0; i < chunkSize; i++ {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+		}
+		cut := end
+		for _, sep := range []string{"\n\n", "\n", ". ", " "} {
+			if i := strings.LastIndex(text[:end], sep); i > end/2 {
+				cut = i + len(sep)
+				break
+			}
+		}
+		chunks = append(chunks, strings.TrimSpace(text[:cut]))
+
+		// step back a bit so nothing gets lost at the seams
+		next := cut
+		for back := 0; back < chunkOverlap && next > 0; back++ {
+			_, size := utf8.DecodeLastRuneInString(text[:next])
+			next -= size
+		}
+		if next <= 0 || next >= cut {
+			next = cut
+		}
+		// start the overlap on a word
+		if i := strings.IndexAny(text[next:cut], " \n"); i >= 0 {
+			next += i + 1
+		}
+		text = strings.TrimSpace(text[next:])
+	}
+	return chunks
+}
+
+func (g *gui) loadKnowledge() {
+	g.knowledge = &knowledgeBase{TopK: defaultTopK}
+	uri, err := storage.Child(g.a.Storage().RootURI(), knowledgeFile)
+	if err != nil {
+		return // no storage on this platform
+	}
+	r, err := storage.Reader(uri)
+	if err == nil {
+		err = json.NewDecoder(r).Decode(g.knowledge)
+		r.Close()
+		if err != nil {
+			g.addStartfunc(func() { dialog.ShowError(fmt.Errorf("error loading knowledge base: %w", err), g.w) })
+		}
+	}
+	g.addSavefunc(func() {
+		w, err := storage.Writer(uri)
+		if err != nil {
+			fmt.Printf("failed to save knowledge base: %s\n", err)
+			return
+		}
+		defer w.Close()
+		err = json.NewEncoder(w).Encode(g.knowledge)
+		if err != nil {
+			fmt.Printf("failed to save knowledge base: %s\n", err)
+		}
+	})
+}
+
+func (kb *knowledgeBase) active() bool {
+	return kb.Enabled && kb.Model != "" && len(kb.Chunks) > 0
+}
+
+type retrieved struct {
+	chunk knowledgeChunk
+	name  string
+	score float64
+}
+
+// Snapshot of what generate needs, the goroutine must not touch gui state
+type knowledgeQuery struct {
+	model  string
+	topk   int
+	chunks []knowledgeChunk
+	names  map[string]string // uri to file name
+}
+
+// nil if the knowledge base is off or empty
+func (g *gui) knowledgeQuery() *knowledgeQuery {
+	kb := g.knowledge
+	if !kb.active() {
+		return nil
+	}
+	names := map[string]string{}
+	for _, s := range kb.Sources {
+		names[s.URI] = s.Name
+	}
+	return &knowledgeQuery{model: kb.Model, topk: max(1, kb.TopK), chunks: kb.Chunks, names: names}
+}
+
+// The top k chunks for query, embeds the query on the way
+func (kq *knowledgeQuery) retrieve(ctx context.Context, client *api.Client, query string) ([]retrieved, error) {
+	resp, err := client.Embed(ctx, &api.EmbedRequest{Model: kq.model, Input: query})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) == 0 {
+		return nil, fmt.Errorf("no embedding for the query")
+	}
+	var hits []retrieved
+	for _, c := range kq.chunks {
+		score := cosineSimilarity(resp.Embeddings[0], c.Vector)
+		if score >= knowledgeMinScore {
+			hits = append(hits, retrieved{c, kq.names[c.Source], score})
+		}
+	}
+	slices.SortFunc(hits, func(a, b retrieved) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return 0
+	})
+	return hits[:min(len(hits), kq.topk)], nil
+}
+
+// The context message that goes in front of the question
+func knowledgePrompt(hits []retrieved) string {
+	var sb strings.Builder
+	sb.WriteString("Use the following excerpts from the user's documents if they help to answer. ")
+	sb.WriteString("Cite them by their number like [1]. If they do not contain the answer, say so.\n\n")
+	for i, h := range hits {
+		fmt.Fprintf(&sb, "[%d] %s, part %d:\n%s\n\n", i+1, h.name, h.chunk.Part, h.chunk.Text)
+	}
+	return sb.String()
+}
+
+// Puts the excerpts right before the last user message and
+// returns what was used, for the citations below the answer
+func (kq *knowledgeQuery) augment(ctx context.Context, client *api.Client, msgs []api.Message) ([]api.Message, []citation, error) {
+	last := -1
+	for i, m := range msgs {
+		if m.Role == "user" {
+			last = i
+		}
+	}
+	if last < 0 {
+		return msgs, nil, nil
+	}
+	hits, err := kq.retrieve(ctx, client, msgs[last].Content)
+	if err != nil || len(hits) == 0 {
+		return msgs, nil, err
+	}
+	var cites []citation
+	for _, h := range hits {
+		cites = append(cites, citation{Source: h.name, Part: h.chunk.Part, Score: h.score})
+	}
+	out := slices.Clone(msgs)
+	out = slices.Insert(out, last, api.Message{Role: "system", Content: knowledgePrompt(hits)})
+	return out, cites, nil
+}
+
+func knowledgeFileWanted(u fyne.URI) bool {
+	return slices.Contains(knowledgeExtensions, strings.ToLower(u.Extension()))
+}
+
+// every readable file below u, or u itself
+func collectKnowledgeFiles(u fyne.URI, explicit bool) []fyne.URI {
+	if ok, _ := storage.CanList(u); ok {
+		children, err := storage.List(u)
+		if err != nil {
+			return nil
+		}
+		var files []fyne.URI
+		for _, c := range children {
+			if strings.HasPrefix(c.Name(), ".") {
+				continue // .git and friends
+			}
+			files = append(files, collectKnowledgeFiles(c, false)...)
+		}
+		return files
+	}
+	// a file the user picked by hand gets the benefit of the doubt
+	if explicit || knowledgeFileWanted(u) {
+		return []fyne.URI{u}
+	}
+	return nil
+}
+
+func readKnowledgeFile(u fyne.URI) (string, error) {
+	r, err := storage.Reader(u)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(io.LimitReader(r, maxKnowledgeFile+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxKnowledgeFile {
+		return "", fmt.Errorf("%s is larger than %d KiB", u.Name(), maxKnowledgeFile>>10)
+	}
+	if !utf8.Valid(b) {
+		return "", fmt.Errorf("%s is not text", u.Name())
+	}
+	return string(b), nil
+}
+
+func (g *gui) knowledgeWidget() fyne.CanvasObject {
+	return widget.NewButton("Knowledge", func() {
+		w := g.a.NewWindow("Knowledge")
+		kb := g.knowledge
+		client := g.client
+		ctx, cancel := context.WithCancel(context.Background())
+		importing := false
+
+		enabled := widget.NewCheck("Answer with my documents", func(b bool) { kb.Enabled = b })
+		enabled.SetChecked(kb.Enabled)
+
+		var names []string
+		for name, m := range g.modelinfo {
+			if embeddingOnly(m, g.modelcaps[m.Digest]) {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		model := widget.NewSelectEntry(names)
+		model.PlaceHolder = "nomic-embed-text"
+		model.SetText(kb.Model)
+
+		topk := widget.NewEntry()
+		topk.SetText(strconv.Itoa(kb.TopK))
+		topk.Validator = validatePositive
+		topk.OnChanged = func(s string) {
+			if n, err := strconv.Atoi(s); err == nil && n > 0 {
+				kb.TopK = n
+			}
+		}
+
+		status := widget.NewLabel("")
+		status.Wrapping = fyne.TextWrapWord
+		progress := widget.NewProgressBar()
+		progress.Hide()
+		sources := container.NewVBox()
+
+		var rebuild func()
+		rebuild = func() {
+			sources.Objects = nil
+			if len(kb.Sources) == 0 {
+				sources.Add(widget.NewLabel("No documents yet"))
+			}
+			for _, src := range kb.Sources {
+				label := widget.NewLabel(fmt.Sprintf("%s (%d parts)", src.Name, src.Parts))
+				label.Truncation = fyne.TextTruncateEllipsis
+				del := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+					if importing {
+						return
+					}
+					kb.Sources = slices.DeleteFunc(kb.Sources, func(s knowledgeSource) bool { return s.URI == src.URI })
+					kb.Chunks = slices.DeleteFunc(kb.Chunks, func(c knowledgeChunk) bool { return c.Source == src.URI })
+					rebuild()
+				})
+				sources.Add(container.NewBorder(nil, nil, nil, del, label))
+			}
+			sources.Refresh()
+		}
+		rebuild()
+
+		// Reads, chunks and embeds the files, one file at a time so a
+		// broken one does not take the others down with it
+		importFiles := func(files []fyne.URI) {
+			embedmodel := strings.TrimSpace(model.Text)
+			if embedmodel == "" {
+				dialog.ShowInformation("Knowledge", "Pick an embedding model first.", w)
+				return
+			}
+			if embedmodel != kb.Model {
+				// old vectors are worthless with another model
+				kb.Model, kb.Sources, kb.Chunks = embedmodel, nil, nil
+				rebuild()
+			}
+			if len(files) == 0 {
+				status.SetText("No text files found")
+				return
+			}
+			importing = true
+			progress.Show()
+			progress.Max = float64(len(files))
+			progress.SetValue(0)
+
+			go func() {
+				var failed []string
+				for i, f := range files {
+					if ctx.Err() != nil {
+						return // window closed
+					}
+					fyne.Do(func() { status.SetText("Reading " + f.Name() + "..."); progress.SetValue(float64(i)) })
+
+					text, err := readKnowledgeFile(f)
+					var chunks []knowledgeChunk
+					if err == nil {
+						for part, c := range chunkText(text) {
+							chunks = append(chunks, knowledgeChunk{Source: f.String(), Part: part + 1, Text: c})
+						}
+						for start := 0; start < len(chunks) && err == nil; start += semanticBatch {
+							batch := chunks[start:min(start+semanticBatch, len(chunks))]
+							var input []string
+							for _, c := range batch {
+								input = append(input, c.Text)
+							}
+							var resp *api.EmbedResponse
+							resp, err = client.Embed(ctx, &api.EmbedRequest{Model: embedmodel, Input: input})
+							if err == nil && len(resp.Embeddings) != len(batch) {
+								err = fmt.Errorf("asked for %d embeddings, got %d", len(batch), len(resp.Embeddings))
+							}
+							if err == nil {
+								for j := range batch {
+									batch[j].Vector = resp.Embeddings[j]
+								}
+							}
+						}
+					}
+					if err != nil {
+						failed = append(failed, fmt.Sprintf("%s: %s", f.Name(), err))
+						continue
+					}
+
+					fyne.DoAndWait(func() {
+						uri := f.String()
+						// importing again replaces the old version
+						kb.Sources = slices.DeleteFunc(kb.Sources, func(s knowledgeSource) bool { return s.URI == uri })
+						kb.Chunks = slices.DeleteFunc(kb.Chunks, func(c knowledgeChunk) bool { return c.Source == uri })
+						kb.Sources = append(kb.Sources, knowledgeSource{URI: uri, Name: path.Base(f.Path()), Parts: len(chunks), Added: time.Now()})
+						kb.Chunks = append(kb.Chunks, chunks...)
+						rebuild()
+					})
+				}
+				fyne.Do(func() {
+					importing = false
+					progress.Hide()
+					status.SetText(fmt.Sprintf("Imported %d of %d files", len(files)-len(failed), len(files)))
+					if len(failed) > 0 {
+						dialog.ShowError(fmt.Errorf("some files were skipped:\n%s", strings.Join(failed, "\n")), w)
+					}
+				})
+			}()
+		}
+
+		addfiles := widget.NewButtonWithIcon("Add file", theme.FileTextIcon(), func() {
+			if importing {
+				return
+			}
+			dialog.ShowFileOpen(func(r fyne.URIReadCloser, err error) {
+				if err != nil || r == nil {
+					return
+				}
+				r.Close()
+				importFiles(collectKnowledgeFiles(r.URI(), true))
+			}, w)
+		})
+		addfolder := widget.NewButtonWithIcon("Add folder", theme.FolderOpenIcon(), func() {
+			if importing {
+				return
+			}
+			dialog.ShowFolderOpen(func(u fyne.ListableURI, err error) {
+				if err != nil || u == nil {
+					return
+				}
+				status.SetText("Looking for text files...")
+				go func() {
+					files := collectKnowledgeFiles(u, false)
+					fyne.Do(func() { importFiles(files) })
+				}()
+			}, w)
+		})
+
+		okbutton := widget.NewButton("Ok", func() { w.Close() })
+		okbutton.Importance = widget.HighImportance
+
+		w.SetOnClosed(cancel)
+		w.SetContent(container.NewBorder(
+			container.NewVBox(
+				enabled,
+				widget.NewForm(
+					widget.NewFormItem("Embedding model", model),
+					widget.NewFormItem("Excerpts per question", topk),
+				),
+				container.NewGridWithColumns(2, addfiles, addfolder),
+				status,
+				progress,
+			),
+			okbutton,
+			nil, nil,
+			container.NewVScroll(sources),
+		))
+		w.Resize(fyne.NewSize(480, 560))
+		w.Show()
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestChunkText(t *testing.T) {
+	para := strings.Repeat("word ", 150) // 750 characters
+	for _, tc := range []struct {
+		name string
+		in   string
+		want int // chunks
+	}{
+		{"empty", "", 0},
+		{"only space", " \n\t ", 0},
+		{"short", "hello world", 1},
+		{"exactly one chunk", strings.Repeat("a", chunkSize), 1},
+		{"two paragraphs", para + "\n\n" + para, 2},
+		{"no spaces at all", strings.Repeat("a", chunkSize*2), 3},
+		{"multibyte", strings.Repeat("ü ", chunkSize), 3},
+		{"crlf", strings.ReplaceAll(para+"\n\n"+para, "\n", "\r\n"), 2},
+	} {
+		chunks := chunkText(tc.in)
+		if len(chunks) != tc.want {
+			t.Errorf("%s: got %d chunks, want %d", tc.name, len(chunks), tc.want)
+		}
+		for i, c := range chunks {
+			if n := utf8.RuneCountInString(c); n > chunkSize || n == 0 {
+				t.Errorf("%s: chunk %d has %d characters", tc.name, i, n)
+			}
+			if !utf8.ValidString(c) || strings.Contains(c, "\r") || c != strings.TrimSpace(c) {
+				t.Errorf("%s: chunk %d is not clean: %q", tc.name, i, c)
+			}
+		}
+	}
+
+	// the pieces overlap, so nothing gets lost at the seams
+	var words []string
+	for i := range 1000 {
+		words = append(words, fmt.Sprint("w", i))
+	}
+	chunks := chunkText(strings.Join(words, " "))
+	for i := 1; i < len(chunks); i++ {
+		first, _, _ := strings.Cut(chunks[i], " ")
+		if !strings.Contains(chunks[i-1], " "+first+" ") {
+			t.Errorf("chunk %d starts with %q, which chunk %d does not end with", i, first, i-1)
+		}
+	}
+	if last := chunks[len(chunks)-1]; !strings.HasSuffix(last, " w999") {
+		t.Errorf("lost the end, last chunk ends in %q", last[len(last)-10:])
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
-	"net/http"
-	"net/url"
-	"slices"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -28,11 +29,34 @@ type gui struct {
 	startfuncs []func()
 	savefuncs  []func()
 	//
-	model      string
-	messages   []api.Message
-	lastserver string // "" triggers first start behaviour
+	model        string
+	modelinfo    map[string]api.ListModelResponse // from the last refresh
+	modelcaps    map[string][]string              // by digest, from client.Show
+	messages     []chatMessage
+	conversation conversationSettings    // system prompt and parameters
+	profiles     map[string]modelProfile // by model name
+	gestures     gestureSettings         // what tapping a message does
+	lastid       int                     // handed out by messageID
+	trash        trashBin                // deleted this session
+	semantic     *semanticIndex
+	knowledge    *knowledgeBase
+	completion   completionState
+	lastserver   string // "" triggers first start behaviour
+	client       *api.Client
+	servers      []serverProfile   // failover order
+	proxies      map[string]string // by host, of the servers we connected to
 	//
-	msgscroller *infiniteScroller // for delete
+	scansettings scanSettings
+	discovery    discovery
+	//
+	msgscroller    *infiniteScroller // for delete
+	usermessage    *entryTroller
+	modelselection *modelPicker
+	stopGenerating context.CancelFunc // nil unless we are streaming
+	modelstatus    *widget.Label      // loading state of the model
+	stopWarmup     context.CancelFunc // nil unless loading
+	serverpanel    fyne.Window        // nil unless open
+	modelmanager   fyne.Window        // nil unless open
 }
 
 // fixme
@@ -53,6 +77,19 @@ type gui struct {
 var isMobile bool
 
 func main() {
+	advertise := flag.String("advertise", "", "no gui, announce the ollama at `host:port` on the LAN via mDNS")
+	flag.Parse()
+	if *advertise != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err := advertiseMDNS(ctx, *advertise)
+		if err != nil {
+			fmt.Printf("advertising failed: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	g := gui{}
 
 	g.a = app.NewWithID("biehdc.priv.ollamagui")
@@ -71,15 +108,19 @@ func main() {
 	})
 
 	// remember and restore the last used server
-	client, _ := api.ClientFromEnvironment()
+	g.loadServers()
+	g.loadScanSettings()
+	g.loadKnownHosts()
+	g.loadConversation()
+	g.loadSemanticIndex()
+	g.loadKnowledge()
+	g.loadCompletion()
+	g.loadGestures()
+	g.client, _ = api.ClientFromEnvironment()
 	g.lastserver = g.a.Preferences().String("lastserver")
 	if g.lastserver != "" {
-		base := url.URL{
-			Scheme: "http",
-			Host:   g.lastserver,
-		}
-		client = api.NewClient(&base, http.DefaultClient)
-		err := client.Heartbeat(context.TODO())
+		g.client = g.profileFor(g.lastserver).client()
+		err := g.client.Heartbeat(context.TODO())
 		if err != nil {
 			g.addStartfunc(func() { dialog.ShowError(errors.New("last used server could not be contacted"), g.w) })
 		}
@@ -136,8 +177,8 @@ func main() {
 	g.addSavefunc(func() { g.a.Preferences().SetString("renderer", normalorrich.Selected) })
 
 	// input handing
-	clientCTX := context.Background()
 	usermessage := NewEntryTroller()
+	g.usermessage = usermessage
 	if isMobile {
 		// opening the softkeyboard resizes the window, but the scroll container
 		// does not respect the fact that we are scrolled to the bottom. now when
@@ -160,7 +201,13 @@ func main() {
 			}()
 		})
 	}
-	usermessage.ActionItem = widget.NewButtonWithIcon("", theme.MailSendIcon(), func() { usermessage.OnSubmitted(usermessage.Text) })
+	usermessage.ActionItem = widget.NewButtonWithIcon("", theme.MailSendIcon(), func() {
+		if g.stopGenerating != nil {
+			g.stopGenerating()
+			return
+		}
+		usermessage.OnSubmitted(usermessage.Text)
+	})
 	usermessage.PlaceHolder = "Type your message..."
 	usermessage.Text = g.a.Preferences().StringWithFallback("lastprompt", "Hello friend. What is your name and task?")
 	g.addSavefunc(func() { g.a.Preferences().SetString("lastprompt", usermessage.Text) })
@@ -176,118 +223,29 @@ func main() {
 			return // ignore empty
 		}
 
-		g.messages = append(g.messages, api.Message{
-			Role:    "user",
-			Content: s,
+		g.confirmModelSwitch(len(g.messages), func() {
+			g.messages = append(g.messages, chatMessage{Message: api.Message{
+				Role:    "user",
+				Content: s,
+			}})
+			g.messages = append(g.messages, chatMessage{Message: api.Message{Role: "assistant"}})
+			g.generate(len(g.messages) - 1)
 		})
-
-		// grey out during response
-		usermessage.Disable()
-		usermessage.ActionItem.(fyne.Disableable).Disable()
-
-		req := &api.ChatRequest{
-			Model:    g.model,
-			Messages: g.messages,
-			// the following is there for user experience
-			// techically the server should set the
-			// "OLLAMA_KEEP_ALIVE=30min" environment variable
-			KeepAlive: &api.Duration{Duration: 30 * time.Minute},
-		}
-
-		g.messages = append(g.messages, api.Message{})
-		index := len(g.messages) - 1
-		g.msgscroller.GoToBottom()
-
-		type opt struct {
-			err error
-			msg api.Message
-		}
-		msgflow := make(chan opt)
-		go func() {
-			defer close(msgflow)
-
-			var msg api.Message
-			msg.Role = "assistant"
-			respFunc := func(resp api.ChatResponse) error {
-				msg.Content += resp.Message.Content
-				msgflow <- opt{msg: msg}
-				return nil
-			}
-
-			err := client.Chat(clientCTX, req, respFunc)
-			if err != nil {
-				msgflow <- opt{err: err, msg: msg}
-			}
-		}()
-
-		go func() {
-			// when we error, we keep the prompt,
-			// so the user can redispatch it
-			preserveUsermessage := false
-			// mainloop
-			for msg := range msgflow {
-				fyne.DoAndWait(func() {
-					g.messages[index] = msg.msg
-
-					if msg.err != nil {
-						dialog.ShowError(msg.err, g.w)
-						preserveUsermessage = true
-						// rollback - remove usermessage
-						// and the space for the ai response
-						g.messages = g.messages[:index-1]
-					}
-
-					if !g.msgscroller.GoToBottomIfAtBottom() {
-						// we still need to refresh even
-						// if we dont scroll to bottom
-						g.msgscroller.RefreshCurrent()
-					}
-				})
-			}
-
-			// cleanup
-			fyne.DoAndWait(func() {
-				if !preserveUsermessage {
-					usermessage.SetText("")
-				}
-				usermessage.ActionItem.(fyne.Disableable).Enable()
-				usermessage.Enable()
-				if isMobile {
-					// for mobile softkeyboard and resizing and
-					// scrolling reasons. see setFocusGainedCallback
-					g.w.Canvas().Unfocus()
-				}
-			})
-		}()
 	}
 	usermessage.Refresh()
 
 	// model
-	const nomodel = "NONE - refresh list"
-	modelselection := widget.NewSelect([]string{}, func(s string) { g.model = s })
-	modelselectionfunc := func() {
-		list, err := client.List(clientCTX)
-		if err != nil {
-			if g.lastserver != "" {
-				// dont pop the box on first starts
-				dialog.ShowError(fmt.Errorf("cant retrieve model list: %w", err), g.w)
-			}
-			modelselection.PlaceHolder = nomodel
-			modelselection.SetOptions([]string{})
-			return
-		}
-
-		var available []string
-		for _, m := range list.Models {
-			available = append(available, m.Name)
-		}
-		modelselection.PlaceHolder = ""
-		modelselection.SetOptions(available)
-	}
+	g.modelselection = newModelPicker(g.selectModel)
+	g.modelcaps = map[string][]string{}
+	g.modelstatus = widget.NewLabel("")
+	g.modelstatus.Wrapping = fyne.TextWrapWord
+	g.modelstatus.Hide()
+	g.loadModelPicker()
+	modelselectionfunc := g.refreshModels
 	g.addStartfunc(modelselectionfunc, func() {
 		g.model = g.a.Preferences().StringWithFallback("model", nomodel)
-		modelselection.SetSelected(g.model)
-		go warmCacheForModel(g.model, client)
+		g.modelselection.SetSelected(g.model)
+		g.warmup()
 	})
 	g.addSavefunc(func() { g.a.Preferences().SetString("model", g.model) })
 
@@ -304,32 +262,40 @@ func main() {
 		manualaddress := widget.NewEntry()
 		manualaddress.PlaceHolder = "127.0.0.1:11434"
 		manualaddress.SetText(g.lastserver)
+		proxyaddress := widget.NewEntry()
+		proxyaddress.PlaceHolder = "Proxy (optional), e.g. socks5://127.0.0.1:1080"
+		proxyaddress.Validator = validateProxy
+		proxyaddress.SetText(g.profileFor(g.lastserver).Proxy)
 		manualaddress.OnSubmitted = func(s string) {
 			if s == "" {
 				s = manualaddress.PlaceHolder
 			}
-			manualaddress.Text = s // for searchForHosts
+			manualaddress.Text = s // for the found hosts
 			defer manualaddress.Refresh()
 
-			base := url.URL{
-				Scheme: "http",
-				Host:   s,
-			}
-			version, err := testServer(&base)
+			profile := g.profileFor(s)
+			profile.Proxy = proxyaddress.Text
+			version, err := testServer(context.TODO(), profile)
 			if err != nil {
 				dialog.ShowError(fmt.Errorf("Failed: %w", err), setwin)
 			} else {
-				client = api.NewClient(&base, http.DefaultClient)
+				g.setProxyFor(s, profile.Proxy)
+				g.client = profile.client()
 				g.lastserver = s
 				dialog.ShowInformation("Success", "Ollama: "+version, setwin)
 				modelselectionfunc() // get list and populate, make user select
+				g.warmup()
 			}
 		}
 
-		hosts := container.NewVBox(widget.NewLabel("Trying to search LAN for ollama..."))
-		go searchForHosts(hosts, manualaddress.OnSubmitted)
+		hosts := g.discoveryWidget(setwin, func(s string) {
+			// found hosts bring their own proxy, if any
+			proxyaddress.SetText(g.profileFor(s).Proxy)
+			manualaddress.OnSubmitted(s)
+		})
 
 		confirm := widget.NewButton("Confirm", func() { manualaddress.OnSubmitted(manualaddress.Text) })
+		proxyaddress.OnSubmitted = func(string) { confirm.OnTapped() }
 		//
 		deletechat := widget.NewButton("Delete History", func() {
 			dialog.ShowConfirm("Delete Chat?", "Is this real?", func(b bool) {
@@ -346,10 +312,7 @@ func main() {
 								break
 							}
 						}
-						fyne.Do(func() {
-							g.messages = []api.Message{}
-							g.msgscroller.scroll.OnScrolled(fyne.Position{}) // redraw
-						})
+						fyne.Do(g.clearHistory)
 					}()
 				}
 			}, setwin)
@@ -359,22 +322,30 @@ func main() {
 		okbutton.Importance = widget.HighImportance
 
 		c := container.NewBorder(
-			container.NewVBox(manualaddress, confirm),
+			container.NewVBox(manualaddress, proxyaddress, confirm),
 			container.NewVBox(
 				container.NewHBox(widget.NewLabel("Render:"), container.NewCenter(normalorrich)),
 				g.helpWidget(),
+				g.serverListWidget(),
+				g.scanSettingsWidget(),
+				g.conversationWidget(),
+				g.knowledgeWidget(),
+				g.gesturesWidget(),
 				deletechat,
 				g.manualThemeScaler(),
 				g.fyneSettings(),
 				okbutton,
 			),
 			nil, nil,
-			container.NewVScroll(hosts),
+			hosts,
 		)
 
 		normalorrich.Refresh() // otherwise doesnt always properly render
 
-		setwin.SetOnClosed(func() { settingswindowlock = false })
+		setwin.SetOnClosed(func() {
+			g.closeDiscovery()
+			settingswindowlock = false
+		})
 
 		setwin.SetContent(c)
 		setwin.Show()
@@ -398,12 +369,14 @@ func main() {
 	top := container.NewBorder(nil, nil, nil,
 		container.NewHBox(
 			widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), modelselectionfunc),
+			g.toolsButton(),
+			widget.NewButtonWithIcon("", theme.ComputerIcon(), g.serverPanel),
 			widget.NewButtonWithIcon("", theme.SettingsIcon(), settingswindow),
 		),
-		modelselection,
+		container.NewVBox(g.modelselection, g.modelstatus),
 	)
 
-	bottom := container.NewVSplit(msgListContainer, container.NewBorder(nil, nil, nil, nil, usermessage))
+	bottom := container.NewVSplit(container.NewStack(msgListContainer, g.snackbarOverlay()), container.NewBorder(nil, nil, nil, nil, usermessage))
 	bottom.Offset = 1.0 // top as big as possible
 	content := container.NewBorder(top, nil, nil, nil, bottom)
 	g.w.SetContent(content)
@@ -441,15 +414,21 @@ func (g *gui) makeMarkdown() makeFuncInfiniteScroller {
 
 			item := widget.NewRichTextWithText("# heading")
 			item.Wrapping = fyne.TextWrapWord
+			// code blocks split the message, last is where it ends
+			var more []fyne.CanvasObject
+			last := item
 
 			if themessage.Role == "user" {
-				item.ParseMarkdown("### User  \n")
-				item.AppendMarkdown(themessage.Content)
+				item.ParseMarkdown("### User" + starMark(themessage) + "  \n")
+				more, last = g.appendMarkdown(item, themessage.Content)
 			} else {
 				message, found := strings.CutPrefix(themessage.Content, "<think>")
 				lenmessage := len(message)
 
-				item.ParseMarkdown("### Assistant  \n")
+				item.ParseMarkdown("### Assistant" + starMark(themessage) + "  \n")
+				if label := modelLabel(themessage); label != "" {
+					item.AppendMarkdown("*" + label + "*")
+				}
 
 				if found && lenmessage > 1 {
 					thinkstring, outputstring, found := strings.Cut(message, "</think>")
@@ -471,44 +450,28 @@ func (g *gui) makeMarkdown() makeFuncInfiniteScroller {
 						}
 
 						item.Segments = append(item.Segments, link, &widget.TextSegment{ /*make a newline*/ })
-						item.AppendMarkdown(outputstring)
+						more, last = g.appendMarkdown(item, outputstring)
 					}
 				} else if !found {
 					// otherwise its simple
-					item.AppendMarkdown(themessage.Content)
+					more, last = g.appendMarkdown(item, themessage.Content)
 				}
 
-				if lenmessage < 1 {
-					item.AppendMarkdown("Loading...")
+				if lenmessage < 1 && themessage.Error == "" {
+					item.AppendMarkdown(messagePlaceholder(themessage))
 				}
+				g.markdownExtras(last, lbound+i, themessage)
 			}
 
 			item.Refresh()
+			last.Refresh()
 
-			content := NewTapperLayer(item,
-				// primary
-				nil,
-				// secondary
-				func(_ *fyne.PointEvent) {
-					g.a.Clipboard().SetContent(themessage.Content)
-					if !isMobile {
-						// android has an on screen notification when something has
-						// been written to the clipboard, only show on desktop
-						g.goodEnoughDialog("Message Clipboarded", themessage.Content)
-					}
-				},
-				// double
-				func(_ *fyne.PointEvent) {
-					// we could alternatively change how item works, split it in 2 and do the hover thing from the dev version
-					//dialog.ShowInformation("stuff", "do fun stuff", g.w)
-					dialog.NewConfirm("Delete Message", "Delete this Message?", func(b bool) {
-						if b {
-							g.messages = slices.Delete(g.messages, lbound+i, lbound+i+1)
-							g.msgscroller.scroll.OnScrolled(fyne.Position{}) // redraw
+			var body fyne.CanvasObject = item
+			if len(more) > 0 {
+				body = container.NewVBox(append([]fyne.CanvasObject{item}, more...)...)
+			}
 
-						}
-					}, g.w).Show()
-				})
+			content := g.messageTappler(body, lbound+i, true)
 
 			objs = append(objs, content, widget.NewSeparator())
 		}
@@ -552,48 +515,25 @@ func (g *gui) makeNormal() makeFuncInfiniteScroller {
 					item.SetText(themessage.Content)
 				}
 
-				if lenmessage < 1 {
-					item.SetText("Loading...")
+				if lenmessage < 1 && themessage.Error == "" {
+					item.SetText(messagePlaceholder(themessage))
 				}
 			}
 
 			item.Refresh()
 
-			content := NewTapperLayer(item,
-				// primary
-				func(_ *fyne.PointEvent) {
-					message, found := strings.CutPrefix(themessage.Content, "<think>")
-					if found && len(message) > 1 {
-						thinkstring, _, found := strings.Cut(message, "</think>")
-						if found {
-							g.goodEnoughDialog("Thinker", thinkstring)
-							return
-						}
-					}
-					//dialog.ShowInformation("Thinker", "there is no thought", g.w)
-				},
-				// secondary
-				func(_ *fyne.PointEvent) {
-					g.a.Clipboard().SetContent(themessage.Content)
-					if !isMobile {
-						// android has an on screen notification when something has
-						// been written to the clipboard, only show on desktop
-						g.goodEnoughDialog("Message Clipboarded", themessage.Content)
-					}
-				},
-				// double
-				func(_ *fyne.PointEvent) {
-					// we could alternatively change how item works, split it in 2 and do the hover thing from the dev version
-					//dialog.ShowInformation("stuff", "do fun stuff", g.w)
-					dialog.NewConfirm("Delete Message", "Delete this Message?", func(b bool) {
-						if b {
-							g.messages = slices.Delete(g.messages, lbound+i, lbound+i+1)
-							g.msgscroller.scroll.OnScrolled(fyne.Position{}) // redraw
+			var body fyne.CanvasObject = item
+			parts := []fyne.CanvasObject{item}
+			if label := strings.TrimSpace(modelLabel(themessage) + starMark(themessage)); label != "" {
+				header := widget.NewLabelWithStyle(label, fyne.TextAlignLeading, fyne.TextStyle{Italic: true})
+				header.Importance = widget.LowImportance
+				parts = append([]fyne.CanvasObject{header}, parts...)
+			}
+			if parts = append(parts, g.normalExtras(lbound+i, themessage)...); len(parts) > 1 {
+				body = container.NewVBox(parts...)
+			}
 
-						}
-					}, g.w).Show()
-				},
-			)
+			content := g.messageTappler(body, lbound+i, false)
 
 			objs = append(objs, content, widget.NewSeparator())
 		}
@@ -0,0 +1,998 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+// TeX math is parsed into a small tree of mathNodes, mathrender.go
+// lays that out and draws it. Anything we dont understand is an
+// error and the source is shown instead.
+
+// What kind of thing an atom is, TeX spaces them by it
+type mathClass int
+
+const (
+	mathOrd   mathClass = iota
+	mathOp              // \sum, \sin
+	mathBin             // +, \times
+	mathRel             // =, \leq
+	mathOpen            // (
+	mathClose           // )
+	mathPunct           // ,
+	mathInner           // fractions and such
+)
+
+type mathNode interface {
+	class() mathClass
+	String() string // a unicode approximation, for copying
+}
+
+// A symbol, a number or an operator name
+type mathAtom struct {
+	text   string
+	cls    mathClass
+	italic bool
+	bold   bool
+	limits bool    // scripts go above and below in display style
+	big    bool    // grows in display style
+	scale  float32 // \big and friends, 0 for normal
+}
+
+type mathRow []mathNode
+
+type mathScripts struct {
+	base, sup, sub mathNode // sup and sub can be nil
+}
+
+type mathFrac struct {
+	num, den    mathNode
+	rule        bool   // \binom has none
+	left, right string // and delimiters instead
+}
+
+type mathRoot struct {
+	index, body mathNode // index can be nil
+}
+
+type mathAccent struct {
+	body mathNode
+	kind string // the command name, hat, vec, overline...
+}
+
+type mathFenced struct {
+	left, right string // "" for \left. and \right.
+	body        mathNode
+}
+
+type mathTable struct {
+	rows        [][]mathNode
+	align       string // per column, repeated: l, c or r
+	pairs       bool   // aligned: rl pairs without space in between
+	left, right string
+}
+
+type mathSpace float32 // in em
+
+type mathText struct {
+	text string
+	bold bool
+}
+
+func (a *mathAtom) class() mathClass    { return a.cls }
+func (r mathRow) class() mathClass      { return mathOrd }
+func (s *mathScripts) class() mathClass { return s.base.class() }
+func (f *mathFrac) class() mathClass    { return mathInner }
+func (r *mathRoot) class() mathClass    { return mathOrd }
+func (a *mathAccent) class() mathClass  { return mathOrd }
+func (f *mathFenced) class() mathClass  { return mathInner }
+func (t *mathTable) class() mathClass   { return mathInner }
+func (s mathSpace) class() mathClass    { return mathOrd }
+func (t *mathText) class() mathClass    { return mathOrd }
+
+var (
+	texGreek = map[string]string{
+		"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ", "varepsilon": "ε", "zeta": "ζ",
+		"eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν",
+		"xi": "ξ", "pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ", "varsigma": "ς", "tau": "τ",
+		"upsilon": "υ", "phi": "ϕ", "varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+		"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π", "Sigma": "Σ",
+		"Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	}
+	texOrd = map[string]string{
+		"nabla": "∇", "partial": "∂", "infty": "∞", "emptyset": "∅", "varnothing": "∅", "forall": "∀",
+		"exists": "∃", "nexists": "∄", "angle": "∠", "hbar": "ℏ", "ell": "ℓ", "Re": "ℜ", "Im": "ℑ",
+		"aleph": "ℵ", "wp": "℘", "prime": "′", "degree": "°", "dagger": "†", "ddagger": "‡", "top": "⊤",
+		"bot": "⊥", "neg": "¬", "lnot": "¬", "therefore": "∴", "because": "∵",
+		"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+		"lvert": "|", "rvert": "|", "vert": "|", "lVert": "‖", "rVert": "‖", "Vert": "‖", "|": "‖",
+		"%": "%", "$": "$", "&": "&", "#": "#", "_": "_",
+	}
+	texBin = map[string]string{
+		"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "·", "ast": "∗", "star": "⋆", "circ": "∘",
+		"bullet": "•", "oplus": "⊕", "ominus": "⊖", "otimes": "⊗", "cup": "∪", "cap": "∩", "setminus": "∖",
+		"wedge": "∧", "land": "∧", "vee": "∨", "lor": "∨", "mod": "mod",
+	}
+	texRel = map[string]string{
+		"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠", "approx": "≈", "equiv": "≡",
+		"sim": "∼", "simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫", "prec": "≺", "succ": "≻",
+		"models": "⊨", "vdash": "⊢", "coloneqq": "≔", "in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂",
+		"supset": "⊃", "subseteq": "⊆", "supseteq": "⊇", "perp": "⊥", "parallel": "∥", "mid": "∣",
+		"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←", "leftrightarrow": "↔", "Rightarrow": "⇒",
+		"Leftarrow": "⇐", "Leftrightarrow": "⇔", "implies": "⟹", "impliedby": "⟸", "iff": "⟺", "mapsto": "↦",
+		"uparrow": "↑", "downarrow": "↓", "longrightarrow": "⟶", "longleftarrow": "⟵", "hookrightarrow": "↪",
+	}
+	texOpen  = map[string]string{"{": "{", "lbrace": "{", "langle": "⟨", "lceil": "⌈", "lfloor": "⌊"}
+	texClose = map[string]string{"}": "}", "rbrace": "}", "rangle": "⟩", "rceil": "⌉", "rfloor": "⌋"}
+	// the bool is whether the scripts go above and below
+	texBigOps = map[string]struct {
+		text   string
+		limits bool
+	}{
+		"sum": {"∑", true}, "prod": {"∏", true}, "coprod": {"∐", true}, "bigcup": {"⋃", true},
+		"bigcap": {"⋂", true}, "bigoplus": {"⨁", true}, "bigotimes": {"⨂", true},
+		"int": {"∫", false}, "iint": {"∬", false}, "iiint": {"∭", false}, "oint": {"∮", false},
+	}
+	texFunctions = map[string]bool{
+		"sin": false, "cos": false, "tan": false, "cot": false, "sec": false, "csc": false, "arcsin": false,
+		"arccos": false, "arctan": false, "sinh": false, "cosh": false, "tanh": false, "log": false,
+		"ln": false, "exp": false, "dim": false, "ker": false, "deg": false, "arg": false,
+		"lim": true, "max": true, "min": true, "sup": true, "inf": true, "det": true, "gcd": true, "Pr": true,
+	}
+	texSpaces = map[string]mathSpace{
+		",": 0.17, ":": 0.22, ">": 0.22, ";": 0.28, "!": -0.17, " ": 0.33, "quad": 1, "qquad": 2,
+	}
+	texBigSizes = map[string]float32{
+		"big": 1.2, "bigl": 1.2, "bigr": 1.2, "Big": 1.6, "Bigl": 1.6, "Bigr": 1.6,
+		"bigg": 2.1, "biggl": 2.1, "biggr": 2.1, "Bigg": 2.6, "Biggl": 2.6, "Biggr": 2.6,
+	}
+	// the delimiters \left, \right and \big take
+	texDelims = map[string]string{
+		"{": "{", "}": "}", "lbrace": "{", "rbrace": "}", "langle": "⟨", "rangle": "⟩",
+		"lvert": "|", "rvert": "|", "vert": "|", "|": "‖", "lVert": "‖", "rVert": "‖", "Vert": "‖",
+		"lceil": "⌈", "rceil": "⌉", "lfloor": "⌊", "rfloor": "⌋",
+	}
+	// combining marks for copying
+	texAccents = map[string]string{
+		"hat": "̂", "widehat": "̂", "bar": "̄", "overline": "̅", "vec": "⃗",
+		"dot": "̇", "ddot": "̈", "tilde": "̃", "widetilde": "̃", "check": "̌",
+		"acute": "́", "grave": "̀", "breve": "̆", "underline": "̲",
+	}
+	texFonts = map[string]map[rune]rune{
+		"mathbb":  {'R': 'ℝ', 'N': 'ℕ', 'Z': 'ℤ', 'Q': 'ℚ', 'C': 'ℂ', 'P': 'ℙ', 'H': 'ℍ', 'E': '𝔼', '1': '𝟙'},
+		"mathcal": {'L': 'ℒ', 'H': 'ℋ', 'F': 'ℱ', 'O': '𝒪', 'N': '𝒩', 'A': '𝒜', 'B': 'ℬ', 'E': 'ℰ', 'M': 'ℳ', 'P': '𝒫', 'S': '𝒮'},
+	}
+	superscripts = map[rune]rune{
+		'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹',
+		'+': '⁺', '-': '⁻', '−': '⁻', '=': '⁼', '(': '⁽', ')': '⁾', 'n': 'ⁿ', 'i': 'ⁱ', 'a': 'ᵃ', 'b': 'ᵇ', 'c': 'ᶜ',
+		'd': 'ᵈ', 'e': 'ᵉ', 'f': 'ᶠ', 'g': 'ᵍ', 'h': 'ʰ', 'j': 'ʲ', 'k': 'ᵏ', 'l': 'ˡ', 'm': 'ᵐ', 'o': 'ᵒ',
+		'p': 'ᵖ', 'r': 'ʳ', 's': 'ˢ', 't': 'ᵗ', 'u': 'ᵘ', 'v': 'ᵛ', 'w': 'ʷ', 'x': 'ˣ', 'y': 'ʸ', 'z': 'ᶻ',
+		'T': 'ᵀ', '′': '′', '∗': '*',
+	}
+	subscripts = map[rune]rune{
+		'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆', '7': '₇', '8': '₈', '9': '₉',
+		'+': '₊', '-': '₋', '−': '₋', '=': '₌', '(': '₍', ')': '₎', 'a': 'ₐ', 'e': 'ₑ', 'h': 'ₕ', 'i': 'ᵢ', 'j': 'ⱼ',
+		'k': 'ₖ', 'l': 'ₗ', 'm': 'ₘ', 'n': 'ₙ', 'o': 'ₒ', 'p': 'ₚ', 'r': 'ᵣ', 's': 'ₛ', 't': 'ₜ', 'u': 'ᵤ',
+		'v': 'ᵥ', 'x': 'ₓ',
+	}
+)
+
+var errTeX = errors.New("unsupported tex")
+
+type texParser struct {
+	src string
+	pos int
+}
+
+func parseTeX(src string) (mathNode, error) {
+	p := &texParser{src: src}
+	rows, err := p.table()
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, fmt.Errorf("%w: unexpected %q", errTeX, p.src[p.pos:])
+	}
+	if len(rows) == 1 && len(rows[0]) == 1 {
+		return rows[0][0], nil
+	}
+	// several lines, aligned if there are &
+	t := &mathTable{rows: rows, align: "c"}
+	for _, r := range rows {
+		if len(r) > 1 {
+			t.align, t.pairs = "rl", true
+		}
+	}
+	return t, nil
+}
+
+func (p *texParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *texParser) peek() rune {
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return r
+}
+
+func (p *texParser) next() rune {
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	return r
+}
+
+func (p *texParser) skipSpace() {
+	for !p.eof() && unicode.IsSpace(p.peek()) {
+		p.next()
+	}
+}
+
+// \name, \, or \\ without the backslash
+func (p *texParser) command() string {
+	start := p.pos
+	for !p.eof() && unicode.IsLetter(p.peek()) {
+		p.next()
+	}
+	if p.pos == start && !p.eof() {
+		p.next() // a single symbol like \{ or \,
+	}
+	return p.src[start:p.pos]
+}
+
+// whether the source continues with \name and not \namesomething
+func (p *texParser) hasCommand(name string) bool {
+	rest, ok := strings.CutPrefix(p.src[p.pos:], `\`+name)
+	if !ok {
+		return false
+	}
+	r, _ := utf8.DecodeRuneInString(rest)
+	return rest == "" || !unicode.IsLetter(r)
+}
+
+// where a row ends: a group, a cell, a line, an environment or \left
+func (p *texParser) atStop() bool {
+	p.skipSpace()
+	if p.eof() {
+		return true
+	}
+	switch p.peek() {
+	case '}', '&':
+		return true
+	case '\\':
+		return strings.HasPrefix(p.src[p.pos:], `\\`) || p.hasCommand("end") || p.hasCommand("right")
+	}
+	return false
+}
+
+// Cells separated by & and lines by \\, until something else stops it
+func (p *texParser) table() ([][]mathNode, error) {
+	rows := [][]mathNode{nil}
+	for {
+		cell, err := p.row()
+		if err != nil {
+			return nil, err
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], rowNode(cell))
+		switch {
+		case strings.HasPrefix(p.src[p.pos:], "&"):
+			p.next()
+		case strings.HasPrefix(p.src[p.pos:], `\\`):
+			p.pos += 2
+			p.skipSpace()
+			if strings.HasPrefix(p.src[p.pos:], "[") {
+				// \\[2pt], the extra space is not worth it
+				end := strings.IndexByte(p.src[p.pos:], ']')
+				if end < 0 {
+					return nil, fmt.Errorf("%w: missing ]", errTeX)
+				}
+				p.pos += end + 1
+			}
+			rows = append(rows, nil)
+		default:
+			// a \\ at the very end makes no line
+			last := rows[len(rows)-1]
+			if len(rows) > 1 && len(last) == 1 && isEmpty(last[0]) {
+				rows = rows[:len(rows)-1]
+			}
+			return rows, nil
+		}
+	}
+}
+
+func isEmpty(n mathNode) bool {
+	r, ok := n.(mathRow)
+	return ok && len(r) == 0
+}
+
+func rowNode(r mathRow) mathNode {
+	if len(r) == 1 {
+		return r[0]
+	}
+	return r
+}
+
+func (p *texParser) row() (mathRow, error) {
+	var row mathRow
+	for !p.atStop() {
+		r := p.peek()
+		if r != '^' && r != '_' {
+			n, err := p.atom()
+			if err != nil {
+				return nil, err
+			}
+			row = append(row, n)
+			continue
+		}
+
+		p.next()
+		var base mathNode = mathRow(nil) // {}^{14}C
+		if len(row) > 0 {
+			base, row = row[len(row)-1], row[:len(row)-1]
+		}
+		s, ok := base.(*mathScripts)
+		if !ok {
+			s = &mathScripts{base: base}
+		}
+		arg, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		slot := &s.sup
+		if r == '_' {
+			slot = &s.sub
+		}
+		if *slot != nil {
+			return nil, fmt.Errorf("%w: double %c", errTeX, r)
+		}
+		*slot = arg
+		row = append(row, s)
+	}
+	return row, nil
+}
+
+// {group} or a single token
+func (p *texParser) argument() (mathNode, error) {
+	p.skipSpace()
+	if p.eof() {
+		return nil, fmt.Errorf("%w: missing argument", errTeX)
+	}
+	if p.peek() == '{' {
+		p.next()
+		return p.group()
+	}
+	if r := p.peek(); unicode.IsDigit(r) {
+		p.next()
+		return &mathAtom{text: string(r)}, nil // x^12 is x²2 in TeX too
+	}
+	return p.atom()
+}
+
+// after the {, up to and including the }
+func (p *texParser) group() (mathNode, error) {
+	row, err := p.row()
+	if err != nil {
+		return nil, err
+	}
+	if p.eof() || p.next() != '}' {
+		return nil, fmt.Errorf("%w: missing }", errTeX)
+	}
+	return rowNode(row), nil
+}
+
+// the raw source of {group}, for \text and \begin
+func (p *texParser) rawArgument() (string, error) {
+	p.skipSpace()
+	if p.eof() || p.next() != '{' {
+		return "", fmt.Errorf("%w: missing {", errTeX)
+	}
+	start, depth := p.pos, 1
+	for !p.eof() {
+		switch p.next() {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return p.src[start : p.pos-1], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: missing }", errTeX)
+}
+
+var texChars = map[rune]*mathAtom{
+	'+': {text: "+", cls: mathBin}, '-': {text: "−", cls: mathBin}, '*': {text: "∗", cls: mathBin},
+	'=': {text: "=", cls: mathRel}, '<': {text: "<", cls: mathRel}, '>': {text: ">", cls: mathRel},
+	':': {text: ":", cls: mathRel}, ',': {text: ",", cls: mathPunct}, ';': {text: ";", cls: mathPunct},
+	'(': {text: "(", cls: mathOpen}, '[': {text: "[", cls: mathOpen},
+	')': {text: ")", cls: mathClose}, ']': {text: "]", cls: mathClose},
+	'!': {text: "!", cls: mathClose}, '?': {text: "?", cls: mathClose}, '\'': {text: "′"},
+}
+
+func (p *texParser) atom() (mathNode, error) {
+	r := p.next()
+	switch {
+	case r == '\\':
+		return p.macro()
+	case r == '{':
+		return p.group()
+	case r == '}':
+		return nil, fmt.Errorf("%w: unexpected }", errTeX)
+	case r == '~':
+		return mathSpace(0.33), nil
+	case r == '#' || r == '$' || r == '%':
+		return nil, fmt.Errorf("%w: unexpected %c", errTeX, r)
+	case unicode.IsDigit(r) || r == '.':
+		// numbers stay together so 3.14 gets no spaces
+		start := p.pos - 1
+		for !p.eof() && (unicode.IsDigit(p.peek()) || p.peek() == '.') {
+			p.next()
+		}
+		return &mathAtom{text: p.src[start:p.pos]}, nil
+	case unicode.IsLetter(r):
+		return &mathAtom{text: string(r), italic: r < unicode.MaxASCII}, nil
+	}
+	if a, ok := texChars[r]; ok {
+		c := *a
+		return &c, nil
+	}
+	return &mathAtom{text: string(r)}, nil
+}
+
+func (p *texParser) macro() (mathNode, error) {
+	name := p.command()
+	if s, ok := texGreek[name]; ok {
+		// lowercase greek is italic in TeX, capitals are not
+		return &mathAtom{text: s, italic: unicode.IsLower([]rune(name)[0])}, nil
+	}
+	if s, ok := texOrd[name]; ok {
+		return &mathAtom{text: s}, nil
+	}
+	if s, ok := texBin[name]; ok {
+		return &mathAtom{text: s, cls: mathBin}, nil
+	}
+	if s, ok := texRel[name]; ok {
+		return &mathAtom{text: s, cls: mathRel}, nil
+	}
+	if s, ok := texOpen[name]; ok {
+		return &mathAtom{text: s, cls: mathOpen}, nil
+	}
+	if s, ok := texClose[name]; ok {
+		return &mathAtom{text: s, cls: mathClose}, nil
+	}
+	if op, ok := texBigOps[name]; ok {
+		return &mathAtom{text: op.text, cls: mathOp, limits: op.limits, big: true}, nil
+	}
+	if limits, ok := texFunctions[name]; ok {
+		return &mathAtom{text: name, cls: mathOp, limits: limits}, nil
+	}
+	if space, ok := texSpaces[name]; ok {
+		return space, nil
+	}
+	if scale, ok := texBigSizes[name]; ok {
+		d, err := p.delimiter()
+		if err != nil {
+			return nil, err
+		}
+		cls := mathOrd
+		switch {
+		case strings.HasSuffix(name, "l"):
+			cls = mathOpen
+		case strings.HasSuffix(name, "r"):
+			cls = mathClose
+		}
+		return &mathAtom{text: d, cls: cls, scale: scale}, nil
+	}
+	if _, ok := texAccents[name]; ok {
+		body, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		return &mathAccent{body: body, kind: name}, nil
+	}
+	if font, ok := texFonts[name]; ok {
+		arg, err := p.rawArgument()
+		if err != nil {
+			return nil, err
+		}
+		return &mathAtom{text: strings.Map(func(r rune) rune {
+			if m, ok := font[r]; ok {
+				return m
+			}
+			return r
+		}, arg)}, nil
+	}
+
+	switch name {
+	case "displaystyle", "textstyle", "limits", "nolimits":
+		return mathRow(nil), nil
+	case "frac", "dfrac", "tfrac", "cfrac", "binom":
+		num, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		den, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		if name == "binom" {
+			return &mathFrac{num: num, den: den, left: "(", right: ")"}, nil
+		}
+		return &mathFrac{num: num, den: den, rule: true}, nil
+	case "sqrt":
+		var index mathNode
+		p.skipSpace()
+		if !p.eof() && p.peek() == '[' {
+			p.next()
+			end := strings.IndexByte(p.src[p.pos:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: missing ]", errTeX)
+			}
+			inner := &texParser{src: p.src[p.pos : p.pos+end]}
+			row, err := inner.row()
+			if err != nil {
+				return nil, err
+			}
+			if !inner.eof() {
+				return nil, fmt.Errorf("%w: unexpected %q", errTeX, inner.src[inner.pos:])
+			}
+			p.pos += end + 1
+			index = rowNode(row)
+		}
+		body, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		return &mathRoot{index: index, body: body}, nil
+	case "text", "textrm", "textit", "textbf", "mbox":
+		s, err := p.rawArgument() // text keeps its spaces and symbols
+		if err != nil {
+			return nil, err
+		}
+		return &mathText{text: s, bold: name == "textbf"}, nil
+	case "mathrm", "mathbf", "mathit", "mathsf", "mathtt", "operatorname", "boldsymbol":
+		arg, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		setFont(arg, func(a *mathAtom) {
+			a.italic = name == "mathit"
+			a.bold = name == "mathbf" || name == "boldsymbol"
+		})
+		if name == "operatorname" {
+			s := arg.String()
+			return &mathAtom{text: s, cls: mathOp}, nil
+		}
+		return arg, nil
+	case "left":
+		left, err := p.delimiter()
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.row()
+		if err != nil {
+			return nil, err
+		}
+		if !p.hasCommand("right") {
+			return nil, fmt.Errorf("%w: \\left without \\right", errTeX)
+		}
+		p.pos += len(`\right`)
+		right, err := p.delimiter()
+		if err != nil {
+			return nil, err
+		}
+		return &mathFenced{left: left, right: right, body: rowNode(body)}, nil
+	case "begin":
+		return p.environment()
+	}
+	return nil, fmt.Errorf("%w: \\%s", errTeX, name)
+}
+
+func (p *texParser) environment() (mathNode, error) {
+	env, err := p.rawArgument()
+	if err != nil {
+		return nil, err
+	}
+	t := &mathTable{align: "c"}
+	switch strings.TrimSuffix(env, "*") {
+	case "matrix", "smallmatrix", "gathered", "gather", "equation", "multline":
+	case "pmatrix":
+		t.left, t.right = "(", ")"
+	case "bmatrix":
+		t.left, t.right = "[", "]"
+	case "Bmatrix":
+		t.left, t.right = "{", "}"
+	case "vmatrix":
+		t.left, t.right = "|", "|"
+	case "Vmatrix":
+		t.left, t.right = "‖", "‖"
+	case "cases":
+		t.left, t.align = "{", "l"
+	case "aligned", "align", "split", "alignat", "alignedat":
+		t.align, t.pairs = "rl", true
+		if strings.HasPrefix(env, "align") && strings.HasSuffix(strings.TrimSuffix(env, "*"), "at") {
+			_, err = p.rawArgument() // the number of columns
+		}
+	case "array":
+		var spec string
+		spec, err = p.rawArgument()
+		t.align = strings.Map(func(r rune) rune {
+			if strings.ContainsRune("lcr", r) {
+				return r
+			}
+			return -1
+		}, spec)
+		if t.align == "" {
+			t.align = "c"
+		}
+	default:
+		return nil, fmt.Errorf("%w: environment %s", errTeX, env)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.rows, err = p.table()
+	if err != nil {
+		return nil, err
+	}
+	if !p.hasCommand("end") {
+		return nil, fmt.Errorf("%w: missing \\end{%s}", errTeX, env)
+	}
+	p.pos += len(`\end`)
+	end, err := p.rawArgument()
+	if err != nil {
+		return nil, err
+	}
+	if end != env {
+		return nil, fmt.Errorf("%w: \\begin{%s} ended by \\end{%s}", errTeX, env, end)
+	}
+	return t, nil
+}
+
+// ( [ | . \{ \langle and the like
+func (p *texParser) delimiter() (string, error) {
+	p.skipSpace()
+	if p.eof() {
+		return "", fmt.Errorf("%w: missing delimiter", errTeX)
+	}
+	r := p.next()
+	switch {
+	case r == '\\':
+		name := p.command()
+		if d, ok := texDelims[name]; ok {
+			return d, nil
+		}
+		return "", fmt.Errorf("%w: delimiter \\%s", errTeX, name)
+	case r == '.':
+		return "", nil
+	case r == '<':
+		return "⟨", nil
+	case r == '>':
+		return "⟩", nil
+	case strings.ContainsRune("()[]|/", r):
+		return string(r), nil
+	}
+	return "", fmt.Errorf("%w: delimiter %c", errTeX, r)
+}
+
+// \mathrm and friends change the atoms below them
+func setFont(n mathNode, f func(*mathAtom)) {
+	switch n := n.(type) {
+	case *mathAtom:
+		if n.cls != mathOp {
+			f(n)
+		}
+	case mathRow:
+		for _, c := range n {
+			setFont(c, f)
+		}
+	case *mathScripts:
+		setFont(n.base, f)
+	}
+}
+
+func (a *mathAtom) String() string { return a.text }
+
+func (r mathRow) String() string {
+	var sb strings.Builder
+	for i, n := range r {
+		spaced := n.class() == mathRel || (n.class() == mathBin && i > 0)
+		if spaced && i > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(n.String())
+		if spaced || n.class() == mathPunct || (n.class() == mathOp && i < len(r)-1) {
+			sb.WriteString(" ")
+		}
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+func (s *mathScripts) String() string {
+	out := s.base.String()
+	for _, script := range []struct {
+		n     mathNode
+		table map[rune]rune
+		mark  string
+	}{{s.sub, subscripts, "_"}, {s.sup, superscripts, "^"}} {
+		if script.n == nil {
+			continue
+		}
+		text := script.n.String()
+		if mapped, ok := mapRunes(text, script.table); ok {
+			out += mapped
+		} else if utf8.RuneCountInString(text) == 1 {
+			out += script.mark + text
+		} else {
+			out += script.mark + "(" + text + ")"
+		}
+	}
+	return out
+}
+
+func (f *mathFrac) String() string {
+	if !f.rule {
+		return "C(" + f.num.String() + ", " + f.den.String() + ")"
+	}
+	return wrapTerm(f.num.String()) + "/" + wrapTerm(f.den.String())
+}
+
+func (r *mathRoot) String() string {
+	index := ""
+	if r.index != nil {
+		index = r.index.String()
+		if sup, ok := mapRunes(index, superscripts); ok {
+			index = sup
+		}
+	}
+	return index + "√" + wrapTerm(r.body.String())
+}
+
+func (a *mathAccent) String() string { return a.body.String() + texAccents[a.kind] }
+
+func (f *mathFenced) String() string { return f.left + f.body.String() + f.right }
+
+func (t *mathTable) String() string {
+	var rows []string
+	for _, r := range t.rows {
+		var cells []string
+		for _, c := range r {
+			if c := c.String(); c != "" {
+				cells = append(cells, c)
+			}
+		}
+		sep := " "
+		if !t.pairs {
+			sep = ", "
+		}
+		rows = append(rows, strings.Join(cells, sep))
+	}
+	return t.left + strings.Join(rows, "; ") + t.right
+}
+
+func (s mathSpace) String() string {
+	if s > 0.2 {
+		return " "
+	}
+	return ""
+}
+
+func (t *mathText) String() string { return t.text }
+
+// (a+b) for the parts of a fraction or root, a stays a
+func wrapTerm(s string) string {
+	if utf8.RuneCountInString(s) <= 1 || strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+	}) < 0 {
+		return s
+	}
+	return "(" + s + ")"
+}
+
+// only if every rune has a counterpart
+func mapRunes(s string, table map[rune]rune) (string, bool) {
+	var sb strings.Builder
+	for _, r := range s {
+		m, ok := table[r]
+		if !ok {
+			return "", false
+		}
+		sb.WriteRune(m)
+	}
+	return sb.String(), true
+}
+
+// A piece of prose, either markdown or math
+type mathPart struct {
+	text    string
+	math    bool
+	display bool
+}
+
+// Finds $...$, $$...$$, \(...\) and \[...\] outside of inline code.
+// Inline dollars need something that is not a space right inside
+// and no digit right after, so "$5 and $10" stays money.
+func splitMath(md string) []mathPart {
+	var parts []mathPart
+	var prose strings.Builder
+	flush := func() {
+		if prose.Len() > 0 {
+			parts = append(parts, mathPart{text: prose.String()})
+			prose.Reset()
+		}
+	}
+	for i := 0; i < len(md); {
+		rest := md[i:]
+		switch {
+		case rest[0] == '`':
+			end := strings.IndexByte(rest[1:], '`')
+			if end < 0 {
+				prose.WriteString(rest)
+				i = len(md)
+				continue
+			}
+			prose.WriteString(rest[:end+2])
+			i += end + 2
+			continue
+		case strings.HasPrefix(rest, `\$`):
+			prose.WriteString(`\$`)
+			i += 2
+			continue
+		}
+
+		var open, closing string
+		display := false
+		switch {
+		case strings.HasPrefix(rest, "$$"):
+			open, closing, display = "$$", "$$", true
+		case strings.HasPrefix(rest, `\[`):
+			open, closing, display = `\[`, `\]`, true
+		case strings.HasPrefix(rest, `\(`):
+			open, closing = `\(`, `\)`
+		case rest[0] == '$':
+			open, closing = "$", "$"
+		}
+		if open == "" {
+			_, size := utf8.DecodeRuneInString(rest)
+			prose.WriteString(rest[:size])
+			i += size
+			continue
+		}
+
+		end := strings.Index(rest[len(open):], closing)
+		inner := ""
+		if end >= 0 {
+			inner = rest[len(open) : len(open)+end]
+		}
+		after := len(open) + end + len(closing)
+		ok := end > 0 && strings.TrimSpace(inner) != ""
+		if ok && open == "$" {
+			ok = !unicode.IsSpace(rune(inner[0])) && !unicode.IsSpace(rune(inner[len(inner)-1])) &&
+				!strings.Contains(inner, "\n\n") &&
+				(after >= len(rest) || !unicode.IsDigit(rune(rest[after])))
+		}
+		if !ok {
+			prose.WriteString(open)
+			i += len(open)
+			continue
+		}
+		flush()
+		parts = append(parts, mathPart{text: strings.TrimSpace(inner), math: true, display: display})
+		i += after
+	}
+	flush()
+	return parts
+}
+
+// Stands in for inline math while the markdown is parsed
+const mathMarkOpen, mathMarkClose = '\uE000', '\uE001'
+
+func mathPlaceholder(i int) string {
+	return string(mathMarkOpen) + strconv.Itoa(i) + string(mathMarkClose)
+}
+
+// Appends md to the rich text with the math rendered. Inline math goes
+// through the markdown as a placeholder and is swapped for its segment
+// afterwards, display math is a block of its own.
+func appendMathMarkdown(rt *widget.RichText, md string) {
+	parts := splitMath(md)
+	if len(parts) == 1 && !parts[0].math {
+		rt.AppendMarkdown(md)
+		return
+	}
+	var pending strings.Builder
+	var inline []widget.RichTextSegment
+	flush := func() {
+		if pending.Len() > 0 {
+			parsed := widget.NewRichTextFromMarkdown(pending.String())
+			rt.Segments = append(rt.Segments, expandMath(parsed.Segments, inline)...)
+			pending.Reset()
+			inline = nil
+		}
+	}
+	for _, p := range parts {
+		switch {
+		case !p.math:
+			pending.WriteString(p.text)
+		case !p.display:
+			pending.WriteString(mathPlaceholder(len(inline)))
+			inline = append(inline, newMathSegment(p.text, false))
+		default:
+			flush()
+			rt.Segments = append(rt.Segments, newMathSegment(p.text, true))
+		}
+	}
+	flush()
+	rt.Refresh()
+}
+
+// Swaps the placeholders in segs for the math segments
+func expandMath(segs []widget.RichTextSegment, maths []widget.RichTextSegment) []widget.RichTextSegment {
+	var out []widget.RichTextSegment
+	for _, seg := range segs {
+		switch s := seg.(type) {
+		case *widget.TextSegment:
+			out = append(out, splitPlaceholders(s, maths)...)
+		case *widget.ParagraphSegment:
+			s.Texts = expandMath(s.Texts, maths)
+			out = append(out, s)
+		case *widget.ListSegment:
+			for i, item := range s.Items {
+				expanded := expandMath([]widget.RichTextSegment{item}, maths)
+				if len(expanded) == 1 {
+					s.Items[i] = expanded[0]
+				} else {
+					s.Items[i] = &widget.ParagraphSegment{Texts: expanded}
+				}
+			}
+			out = append(out, s)
+		case *widget.HyperlinkSegment:
+			// links only take text
+			for i, m := range maths {
+				s.Text = strings.Replace(s.Text, mathPlaceholder(i), m.Textual(), 1)
+			}
+			out = append(out, s)
+		default:
+			out = append(out, seg)
+		}
+	}
+	return out
+}
+
+func splitPlaceholders(s *widget.TextSegment, maths []widget.RichTextSegment) []widget.RichTextSegment {
+	var out []widget.RichTextSegment
+	text := s.Text
+	inline := s.Style
+	inline.Inline = true
+	for {
+		start := strings.IndexRune(text, mathMarkOpen)
+		if start < 0 {
+			break
+		}
+		length := strings.IndexRune(text[start:], mathMarkClose)
+		if length < 0 {
+			break
+		}
+		n, err := strconv.Atoi(text[start+utf8.RuneLen(mathMarkOpen) : start+length])
+		if err != nil || n < 0 || n >= len(maths) {
+			break
+		}
+		if start > 0 {
+			out = append(out, &widget.TextSegment{Style: inline, Text: text[:start]})
+		}
+		out = append(out, maths[n])
+		text = text[start+length+utf8.RuneLen(mathMarkClose):]
+	}
+	if len(out) == 0 {
+		return []widget.RichTextSegment{s}
+	}
+	// the rest keeps the original style, which might end the line
+	if text != "" || !s.Style.Inline {
+		out = append(out, &widget.TextSegment{Style: s.Style, Text: text})
+	}
+	return out
+}
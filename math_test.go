@@ -0,0 +1,130 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+func TestParseTeX(t *testing.T) {
+	for _, tc := range []struct {
+		src, want string // want starting with ! is the error
+	}{
+		{`x^2`, "x²"},
+		{`x_{10}`, "x₁₀"},
+		{`x^{y^z}`, "x^(yᶻ)"},
+		{`\frac{a+b}{2}`, "(a + b)/2"},
+		{`\frac12`, "1/2"},
+		{`\binom{n}{k}`, "C(n, k)"},
+		{`\sqrt[3]{x}`, "³√x"},
+		{`\alpha + \beta = \gamma`, "α + β = γ"},
+		{`\left( x \right)`, "(x)"},
+		{`\begin{pmatrix} a & b \\ c & d \end{pmatrix}`, "(a, b; c, d)"},
+		{`\text{if } x`, "if x"},
+		{`\hat{x}`, "x̂"},
+		{`\mathbb{R}`, "ℝ"},
+		{`\sin x`, "sin x"},
+		{`\sum_{i=1}^n i`, "∑_(i = 1)ⁿ i"},
+		{`\frac{a}{`, "!missing }"},
+		{`}`, `!unexpected "}"`},
+		{`a^`, "!missing argument"},
+		{`\left( x`, `!\left without \right`},
+		{`\unknowncmd`, `!\unknowncmd`},
+		{`\begin{foo}x\end{foo}`, "!environment foo"},
+	} {
+		n, err := parseTeX(tc.src)
+		switch {
+		case strings.HasPrefix(tc.want, "!"):
+			if err == nil || !strings.HasSuffix(err.Error(), tc.want[1:]) {
+				t.Errorf("%s: got error %v, want %q", tc.src, err, tc.want[1:])
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tc.src, err)
+		case n.String() != tc.want:
+			t.Errorf("%s: got %q, want %q", tc.src, n.String(), tc.want)
+		}
+	}
+}
+
+func TestSplitMath(t *testing.T) {
+	prose := func(s string) mathPart { return mathPart{text: s} }
+	inline := func(s string) mathPart { return mathPart{text: s, math: true} }
+	display := func(s string) mathPart { return mathPart{text: s, math: true, display: true} }
+
+	for _, tc := range []struct {
+		in   string
+		want []mathPart
+	}{
+		{"", nil},
+		{"no math", []mathPart{prose("no math")}},
+		{"a $x$ b", []mathPart{prose("a "), inline("x"), prose(" b")}},
+		{`a \(x\) b`, []mathPart{prose("a "), inline("x"), prose(" b")}},
+		{"$$ x $$", []mathPart{display("x")}},
+		{`\[x\]`, []mathPart{display("x")}},
+		{"$5 and $10", []mathPart{prose("$5 and $10")}},
+		{"costs $5, $x$", []mathPart{prose("costs $5, "), inline("x")}},
+		{"$ x$", []mathPart{prose("$ x$")}},
+		{"$x$5", []mathPart{prose("$x$5")}},
+		{"$a\n\nb$", []mathPart{prose("$a\n\nb$")}},
+		{`\$x\$`, []mathPart{prose(`\$x\$`)}},
+		{"`$x$` $y$", []mathPart{prose("`$x$` "), inline("y")}},
+		{"unclosed `$x$", []mathPart{prose("unclosed `$x$")}},
+		{"$$x", []mathPart{prose("$$x")}},
+		{"$$$$", []mathPart{prose("$$$$")}},
+	} {
+		if got := splitMath(tc.in); !slices.Equal(got, tc.want) {
+			t.Errorf("%q: got %+v, want %+v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestExpandMath(t *testing.T) {
+	maths := []widget.RichTextSegment{newMathSegment("x^2", false), newMathSegment(`\alpha`, false)}
+	text := func(s string) *widget.TextSegment {
+		return &widget.TextSegment{Style: widget.RichTextStyleInline, Text: s}
+	}
+	textual := func(segs []widget.RichTextSegment) []string {
+		var out []string
+		for _, s := range segs {
+			out = append(out, s.Textual())
+		}
+		return out
+	}
+
+	for _, tc := range []struct {
+		name string
+		in   *widget.TextSegment
+		want []string
+	}{
+		{"no placeholder", text("plain"), []string{"plain"}},
+		{"one", text("a " + mathPlaceholder(0) + " b"), []string{"a ", "x²", " b"}},
+		{"both, back to back", text(mathPlaceholder(0) + mathPlaceholder(1)), []string{"x²", "α"}},
+		{"unknown index stays", text(mathPlaceholder(5)), []string{mathPlaceholder(5)}},
+		{"a paragraph keeps its end", &widget.TextSegment{Style: widget.RichTextStyleParagraph, Text: mathPlaceholder(1)},
+			[]string{"α", ""}},
+	} {
+		if got := textual(expandMath([]widget.RichTextSegment{tc.in}, maths)); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	// into paragraphs, list items and link texts
+	link := &widget.HyperlinkSegment{Text: "see " + mathPlaceholder(1)}
+	list := &widget.ListSegment{Items: []widget.RichTextSegment{text(mathPlaceholder(0) + " item")}}
+	para := &widget.ParagraphSegment{Texts: []widget.RichTextSegment{text(mathPlaceholder(0))}}
+	got := expandMath([]widget.RichTextSegment{para, list, link}, maths)
+	if len(got) != 3 {
+		t.Fatalf("got %d segments, want 3", len(got))
+	}
+	if got := textual(para.Texts); !slices.Equal(got, []string{"x²"}) {
+		t.Errorf("paragraph: got %q", got)
+	}
+	if item, ok := list.Items[0].(*widget.ParagraphSegment); !ok || !slices.Equal(textual(item.Texts), []string{"x²", " item"}) {
+		t.Errorf("list item: got %#v", list.Items[0])
+	}
+	if link.Text != "see α" {
+		t.Errorf("link: got %q", link.Text)
+	}
+}
@@ -0,0 +1,622 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Lays out the tree from math.go about the way TeX does and draws it
+// with canvas texts, lines and circles. Lengths are in font sizes
+// (em), y grows downwards from the baseline.
+
+// How far letters reach above and below the baseline. The font
+// metrics include the line spacing, which would make every fraction
+// and parenthesis far too big.
+const mathAscent, mathDescent = 0.78, 0.22
+
+type mathStyle struct {
+	size    float32 // of the current font
+	base    float32 // of the text around, scripts shrink relative to it
+	display bool    // $$, limits go above and below
+	level   int     // 0 normal, 1 script, 2 script of a script
+}
+
+func (st mathStyle) em(f float32) float32 { return f * st.size }
+
+// the height fraction bars and operators are centred on
+func (st mathStyle) axis() float32 { return st.em(0.25) }
+
+// fraction bars and root strokes
+func (st mathStyle) rule() float32 { return max(1, st.size/18) }
+
+func (st mathStyle) script() mathStyle {
+	st.display = false
+	st.level = min(st.level+1, 2)
+	st.size = st.base * [...]float32{1, 0.7, 0.5}[st.level]
+	return st
+}
+
+// what numerators and denominators use
+func (st mathStyle) fraction() mathStyle {
+	if st.display {
+		st.display = false
+		return st
+	}
+	return st.script()
+}
+
+type mathGlyph struct {
+	text  string
+	size  float32
+	style fyne.TextStyle
+	x, y  float32 // top left of the text
+}
+
+type mathRule struct{ x1, y1, x2, y2, width float32 }
+
+type mathDot struct{ x, y, r float32 }
+
+type mathBox struct {
+	w, asc, desc float32
+	glyphs       []mathGlyph
+	rules        []mathRule
+	dots         []mathDot
+}
+
+// Copies child in with its baseline origin at x, y
+func (b *mathBox) place(child *mathBox, x, y float32) {
+	for _, g := range child.glyphs {
+		g.x, g.y = g.x+x, g.y+y
+		b.glyphs = append(b.glyphs, g)
+	}
+	for _, r := range child.rules {
+		r.x1, r.y1, r.x2, r.y2 = r.x1+x, r.y1+y, r.x2+x, r.y2+y
+		b.rules = append(b.rules, r)
+	}
+	for _, d := range child.dots {
+		d.x, d.y = d.x+x, d.y+y
+		b.dots = append(b.dots, d)
+	}
+	b.asc = max(b.asc, child.asc-y)
+	b.desc = max(b.desc, child.desc+y)
+}
+
+func (b *mathBox) line(x1, y1, x2, y2, width float32) {
+	b.rules = append(b.rules, mathRule{x1, y1, x2, y2, width})
+}
+
+// width and baseline as the driver draws it
+func measureMath(text string, size float32, style fyne.TextStyle) (float32, float32) {
+	s, base := fyne.CurrentApp().Driver().RenderedTextSize(text, size, style, nil)
+	return s.Width, base
+}
+
+func glyphBox(text string, size float32, style fyne.TextStyle) *mathBox {
+	w, base := measureMath(text, size, style)
+	return &mathBox{
+		w: w, asc: mathAscent * size, desc: mathDescent * size,
+		glyphs: []mathGlyph{{text: text, size: size, style: style, y: -base}},
+	}
+}
+
+// A glyph in another size, moved so the point centre font sizes above
+// its baseline sits on the axis
+func centredGlyph(text string, size, centre float32, st mathStyle) *mathBox {
+	w, base := measureMath(text, size, fyne.TextStyle{})
+	y := -st.axis() + centre*size
+	return &mathBox{
+		w: w, asc: mathAscent*size - y, desc: mathDescent*size + y,
+		glyphs: []mathGlyph{{text: text, size: size, y: y - base}},
+	}
+}
+
+func layoutMath(n mathNode, st mathStyle) *mathBox {
+	switch n := n.(type) {
+	case *mathAtom:
+		return layoutAtom(n, st)
+	case mathRow:
+		return layoutRow(n, st)
+	case *mathScripts:
+		return layoutScripts(n, st)
+	case *mathFrac:
+		return layoutFrac(n, st)
+	case *mathRoot:
+		return layoutRoot(n, st)
+	case *mathAccent:
+		return layoutAccent(n, st)
+	case *mathFenced:
+		return fence(n.left, n.right, layoutMath(n.body, st), st)
+	case *mathTable:
+		return layoutTable(n, st)
+	case mathSpace:
+		return &mathBox{w: st.em(float32(n))}
+	case *mathText:
+		return glyphBox(n.text, st.size, fyne.TextStyle{Bold: n.bold})
+	}
+	return &mathBox{}
+}
+
+func layoutAtom(a *mathAtom, st mathStyle) *mathBox {
+	switch {
+	case a.scale > 0:
+		return delimiterBox(a.text, st.em(a.scale), st)
+	case a.big && st.display:
+		return centredGlyph(a.text, st.em(1.8), 0.33, st)
+	case a.big:
+		return centredGlyph(a.text, st.em(1.2), 0.33, st)
+	}
+	b := glyphBox(a.text, st.size, fyne.TextStyle{Italic: a.italic, Bold: a.bold})
+	if a.italic {
+		b.w += st.em(0.03) // the slant pokes out on the right
+	}
+	return b
+}
+
+// A delimiter about height high, centred on the axis
+func delimiterBox(d string, height float32, st mathStyle) *mathBox {
+	switch d {
+	case "":
+		return &mathBox{w: st.em(0.1)}
+	case "|", "‖":
+		b := &mathBox{w: st.em(0.3), asc: st.axis() + height/2, desc: height/2 - st.axis()}
+		xs := []float32{st.em(0.15)}
+		if d == "‖" {
+			b.w, xs = st.em(0.45), []float32{st.em(0.15), st.em(0.3)}
+		}
+		for _, x := range xs {
+			b.line(x, -b.asc, x, b.desc, st.rule())
+		}
+		return b
+	}
+	// the ink of brackets is about a font size high
+	return centredGlyph(d, max(st.size, height), 0.25, st)
+}
+
+const mathNone mathClass = -1
+
+// The space between two neighbours, in em. The table of TeX, a bit
+// simplified.
+func mathGap(prev, cur mathClass, script bool) float32 {
+	switch {
+	case prev == mathNone, prev == mathOpen, cur == mathClose, cur == mathPunct:
+		return 0
+	case prev == mathRel && cur == mathRel:
+		return 0
+	case prev == mathRel, cur == mathRel:
+		if script {
+			return 0
+		}
+		return 0.28
+	case prev == mathBin, cur == mathBin:
+		if script {
+			return 0
+		}
+		return 0.22
+	case prev == mathOp:
+		if cur == mathOpen {
+			return 0 // sin(x)
+		}
+		return 0.17
+	case cur == mathOp:
+		return 0.17
+	case prev == mathPunct, prev == mathInner, cur == mathInner:
+		if script {
+			return 0
+		}
+		return 0.17
+	}
+	return 0
+}
+
+func layoutRow(r mathRow, st mathStyle) *mathBox {
+	b := &mathBox{}
+	prev := mathNone
+	for i, n := range r {
+		if space, ok := n.(mathSpace); ok {
+			b.w += st.em(float32(space))
+			continue
+		}
+		cls := n.class()
+		if cls == mathBin {
+			// the minus in -x or a = -b is a sign
+			switch prev {
+			case mathNone, mathBin, mathRel, mathOpen, mathPunct, mathOp:
+				cls = mathOrd
+			}
+			if i == len(r)-1 {
+				cls = mathOrd
+			}
+		}
+		b.w += st.em(mathGap(prev, cls, st.level > 0))
+		child := layoutMath(n, st)
+		b.place(child, b.w, 0)
+		b.w += child.w
+		prev = cls
+	}
+	return b
+}
+
+func layoutScripts(s *mathScripts, st mathStyle) *mathBox {
+	base := layoutMath(s.base, st)
+	var sup, sub *mathBox
+	if s.sup != nil {
+		sup = layoutMath(s.sup, st.script())
+	}
+	if s.sub != nil {
+		sub = layoutMath(s.sub, st.script())
+	}
+	b := &mathBox{w: base.w}
+
+	if a, ok := s.base.(*mathAtom); ok && a.limits && st.display {
+		gap := st.em(0.1)
+		if sup != nil {
+			b.w = max(b.w, sup.w)
+		}
+		if sub != nil {
+			b.w = max(b.w, sub.w)
+		}
+		b.place(base, (b.w-base.w)/2, 0)
+		if sup != nil {
+			b.place(sup, (b.w-sup.w)/2, -base.asc-gap-sup.desc)
+		}
+		if sub != nil {
+			b.place(sub, (b.w-sub.w)/2, base.desc+gap+sub.asc)
+		}
+		return b
+	}
+
+	// scripts of tall things like fractions go further out
+	b.place(base, 0, 0)
+	up := max(st.em(0.4), base.asc-st.em(0.4))
+	down := max(st.em(0.2), base.desc-st.em(0.1))
+	if sup != nil && sub != nil {
+		down = max(down, st.em(0.3))
+	}
+	w := float32(0)
+	if sup != nil {
+		b.place(sup, base.w, -up)
+		w = sup.w
+	}
+	if sub != nil {
+		b.place(sub, base.w, down)
+		w = max(w, sub.w)
+	}
+	b.w += w
+	return b
+}
+
+func layoutFrac(f *mathFrac, st mathStyle) *mathBox {
+	num := layoutMath(f.num, st.fraction())
+	den := layoutMath(f.den, st.fraction())
+	rule, gap, pad := st.rule(), st.em(0.08), st.em(0.1)
+	if !f.rule {
+		rule, gap = 0, st.em(0.15)
+	}
+	b := &mathBox{w: max(num.w, den.w) + 2*pad}
+	axis := st.axis()
+	b.place(num, (b.w-num.w)/2, -axis-rule/2-gap-num.desc)
+	b.place(den, (b.w-den.w)/2, -axis+rule/2+gap+den.asc)
+	if f.rule {
+		b.line(0, -axis, b.w, -axis, rule)
+	}
+	if f.left != "" || f.right != "" {
+		return fence(f.left, f.right, b, st)
+	}
+	return b
+}
+
+// The body between two delimiters grown to its height
+func fence(left, right string, body *mathBox, st mathStyle) *mathBox {
+	axis := st.axis()
+	half := max(body.asc-axis, body.desc+axis, st.em(0.5))
+	b := &mathBox{}
+	for _, part := range []*mathBox{delimiterBox(left, 2*half, st), body, delimiterBox(right, 2*half, st)} {
+		b.place(part, b.w, 0)
+		b.w += part.w
+	}
+	return b
+}
+
+// The radical sign is drawn, a font one does not grow
+func layoutRoot(r *mathRoot, st mathStyle) *mathBox {
+	body := layoutMath(r.body, st)
+	rule := st.rule()
+	sign := st.em(0.55)
+	top := -body.asc - st.em(0.12) // the bar over the body
+	bottom := body.desc
+	mid := bottom - (bottom-top)*0.4
+
+	b := &mathBox{}
+	x := float32(0)
+	if r.index != nil {
+		index := layoutMath(r.index, st.script().script())
+		x = max(0, index.w-sign*0.45)
+		b.place(index, x+sign*0.45-index.w, mid-st.em(0.15))
+	}
+	b.line(x, mid+st.em(0.05), x+sign*0.22, mid-st.em(0.05), rule)
+	b.line(x+sign*0.22, mid-st.em(0.05), x+sign*0.5, bottom, rule*1.8)
+	b.line(x+sign*0.5, bottom, x+sign, top, rule)
+	b.line(x+sign, top, x+sign+body.w+st.em(0.1), top, rule)
+	b.place(body, x+sign, 0)
+	b.w = x + sign + body.w + st.em(0.1)
+	b.asc = max(b.asc, -top+rule)
+	return b
+}
+
+func layoutAccent(a *mathAccent, st mathStyle) *mathBox {
+	body := layoutMath(a.body, st)
+	b := &mathBox{w: body.w}
+	b.place(body, 0, 0)
+	rule := st.rule()
+
+	cx, half := body.w/2, min(body.w/2, st.em(0.22))
+	if atom, ok := a.body.(*mathAtom); ok && atom.italic {
+		cx += st.em(0.05) // over the top of the slanted letter
+	}
+	switch a.kind {
+	case "overline", "underline", "widehat", "widetilde":
+		half = body.w / 2
+	case "vec":
+		half = max(half, st.em(0.25))
+	}
+	if a.kind == "underline" {
+		y := body.desc + st.em(0.08)
+		b.line(0, y, body.w, y, rule)
+		b.desc = max(b.desc, y+rule)
+		return b
+	}
+
+	bottom := -body.asc - st.em(0.05)
+	top := bottom - st.em(0.2)
+	mid := (top + bottom) / 2
+	left, right := cx-half, cx+half
+	switch a.kind {
+	case "hat", "widehat":
+		b.line(left, bottom, cx, top, rule)
+		b.line(cx, top, right, bottom, rule)
+	case "check":
+		b.line(left, top, cx, bottom, rule)
+		b.line(cx, bottom, right, top, rule)
+	case "breve":
+		b.line(left, top, cx, bottom, rule)
+		b.line(cx, bottom, right, top, rule)
+		top += st.em(0.05) // a flatter v than check
+	case "bar", "overline":
+		b.line(left, mid, right, mid, rule)
+	case "vec":
+		head := st.em(0.1)
+		b.line(left, mid, right, mid, rule)
+		b.line(right-head, mid-head, right, mid, rule)
+		b.line(right-head, mid+head, right, mid, rule)
+	case "dot":
+		b.dots = append(b.dots, mathDot{cx, mid, st.em(0.05)})
+	case "ddot":
+		b.dots = append(b.dots, mathDot{cx - st.em(0.1), mid, st.em(0.05)}, mathDot{cx + st.em(0.1), mid, st.em(0.05)})
+	case "tilde", "widetilde":
+		d := (bottom - top) / 4
+		xs := []float32{left, left + (right-left)/3, left + 2*(right-left)/3, right}
+		ys := []float32{mid + d, mid - d, mid + d, mid - d}
+		for i := range 3 {
+			b.line(xs[i], ys[i], xs[i+1], ys[i+1], rule)
+		}
+	case "acute":
+		b.line(cx-half/2, bottom, cx+half/2, top, rule)
+	case "grave":
+		b.line(cx-half/2, top, cx+half/2, bottom, rule)
+	}
+	b.asc = max(b.asc, -top+rule)
+	return b
+}
+
+func layoutTable(t *mathTable, st mathStyle) *mathBox {
+	cst := st
+	if !t.pairs {
+		cst.display = false // matrices and cases are set smaller
+	}
+	cols := 0
+	for _, row := range t.rows {
+		cols = max(cols, len(row))
+	}
+	widths := make([]float32, cols)
+	ascs := make([]float32, len(t.rows))
+	descs := make([]float32, len(t.rows))
+	cells := make([][]*mathBox, len(t.rows))
+	for i, row := range t.rows {
+		// empty lines keep their height
+		ascs[i], descs[i] = st.em(mathAscent), st.em(mathDescent)
+		for j, cell := range row {
+			if t.pairs && j%2 == 1 {
+				// so the = of &= is spaced like a relation
+				if r, ok := cell.(mathRow); ok {
+					cell = append(mathRow{mathRow(nil)}, r...)
+				} else {
+					cell = mathRow{mathRow(nil), cell}
+				}
+			}
+			box := layoutMath(cell, cst)
+			cells[i] = append(cells[i], box)
+			widths[j] = max(widths[j], box.w)
+			ascs[i], descs[i] = max(ascs[i], box.asc), max(descs[i], box.desc)
+		}
+	}
+	colgap := func(j int) float32 {
+		switch {
+		case !t.pairs:
+			return st.em(1)
+		case j%2 == 0:
+			return 0
+		}
+		return st.em(2)
+	}
+	rowgap := st.em(0.25)
+
+	height := rowgap * float32(len(t.rows)-1)
+	for i := range t.rows {
+		height += ascs[i] + descs[i]
+	}
+	b := &mathBox{}
+	y := -st.axis() - height/2
+	for i, row := range cells {
+		y += ascs[i]
+		x := float32(0)
+		for j, cell := range row {
+			dx := (widths[j] - cell.w) / 2
+			switch t.align[j%len(t.align)] {
+			case 'l':
+				dx = 0
+			case 'r':
+				dx = widths[j] - cell.w
+			}
+			b.place(cell, x+dx, y)
+			x += widths[j] + colgap(j)
+		}
+		y += descs[i] + rowgap
+	}
+	for j, w := range widths {
+		b.w += w
+		if j < cols-1 {
+			b.w += colgap(j)
+		}
+	}
+	b.asc = max(b.asc, st.axis()+height/2)
+	b.desc = max(b.desc, height/2-st.axis())
+	if t.left != "" || t.right != "" {
+		return fence(t.left, t.right, b, st)
+	}
+	return b
+}
+
+// Math in the rich text, either inline or a block of its own
+type mathSegment struct {
+	node    mathNode
+	display bool
+}
+
+// The source in code style if it is nothing we can draw
+func newMathSegment(src string, display bool) widget.RichTextSegment {
+	node, err := parseTeX(src)
+	switch {
+	case err == nil:
+		return &mathSegment{node: node, display: display}
+	case display:
+		return &widget.TextSegment{Style: widget.RichTextStyleCodeBlock, Text: src}
+	}
+	return &widget.TextSegment{Style: widget.RichTextStyleCodeInline, Text: "$" + src + "$"}
+}
+
+func (m *mathSegment) Inline() bool { return !m.display }
+
+func (m *mathSegment) Textual() string { return m.node.String() }
+
+func (m *mathSegment) Visual() fyne.CanvasObject {
+	c := container.NewWithoutLayout()
+	m.build(c)
+	if m.display {
+		return container.NewHScroll(c) // like code blocks
+	}
+	return c
+}
+
+// rebuilt when the theme changed colours or sizes
+func (m *mathSegment) Update(o fyne.CanvasObject) {
+	if scroll, ok := o.(*container.Scroll); ok {
+		o = scroll.Content
+	}
+	c := o.(*fyne.Container)
+	if l, ok := c.Layout.(*mathLayout); ok && l.size == theme.TextSize() && l.fg == theme.Color(theme.ColorNameForeground) {
+		return
+	}
+	m.build(c)
+	c.Refresh()
+}
+
+func (m *mathSegment) Select(_, _ fyne.Position) {}
+
+func (m *mathSegment) SelectedText() string { return "" }
+
+func (m *mathSegment) Unselect() {}
+
+func (m *mathSegment) build(c *fyne.Container) {
+	size := theme.TextSize()
+	box := layoutMath(m.node, mathStyle{size: size, base: size, display: m.display})
+	fg := theme.Color(theme.ColorNameForeground)
+	text, baseline := fyne.CurrentApp().Driver().RenderedTextSize("M", size, fyne.TextStyle{}, nil)
+
+	c.Objects = nil
+	for _, g := range box.glyphs {
+		t := canvas.NewText(g.text, fg)
+		t.TextSize, t.TextStyle = g.size, g.style
+		c.Objects = append(c.Objects, t)
+	}
+	for _, r := range box.rules {
+		l := canvas.NewLine(fg)
+		l.StrokeWidth = r.width
+		c.Objects = append(c.Objects, l)
+	}
+	for range box.dots {
+		c.Objects = append(c.Objects, canvas.NewCircle(fg))
+	}
+	c.Layout = &mathLayout{
+		box: box, display: m.display, pad: size * 0.3,
+		baseline: baseline, line: text.Height, size: size, fg: fg,
+	}
+}
+
+// Puts the drawn parts where the box says
+type mathLayout struct {
+	box      *mathBox
+	display  bool
+	pad      float32
+	baseline float32 // of the text around inline math
+	line     float32 // and its height
+	// what it was built with
+	size float32
+	fg   color.Color
+}
+
+// Rich text lines up the baselines of its texts by moving everything
+// that is not text down to the tallest one, so inline math draws its
+// ascent above its top and is only as high as text baseline plus depth.
+// Being exactly as high as the text would skip that move.
+func (l *mathLayout) MinSize([]fyne.CanvasObject) fyne.Size {
+	if l.display {
+		return fyne.NewSize(l.box.w+2*l.pad, l.box.asc+l.box.desc+2*l.pad)
+	}
+	h := l.baseline + l.box.desc
+	if h == l.line {
+		h++
+	}
+	return fyne.NewSize(l.box.w, h)
+}
+
+func (l *mathLayout) Layout(objects []fyne.CanvasObject, size fyne.Size) {
+	x0, y0 := float32(0), float32(0)
+	if l.display {
+		// whole pixels keep the fraction bars sharp
+		x0 = float32(math.Round(float64(max(l.pad, (size.Width-l.box.w)/2))))
+		y0 = float32(math.Round(float64((size.Height-l.box.asc-l.box.desc)/2 + l.box.asc)))
+	}
+	i := 0
+	for _, g := range l.box.glyphs {
+		objects[i].Move(fyne.NewPos(x0+g.x, y0+g.y))
+		objects[i].Resize(objects[i].MinSize())
+		i++
+	}
+	for _, r := range l.box.rules {
+		line := objects[i].(*canvas.Line)
+		line.Position1 = fyne.NewPos(x0+r.x1, y0+r.y1)
+		line.Position2 = fyne.NewPos(x0+r.x2, y0+r.y2)
+		i++
+	}
+	for _, d := range l.box.dots {
+		objects[i].Move(fyne.NewPos(x0+d.x-d.r, y0+d.y-d.r))
+		objects[i].Resize(fyne.NewSize(2*d.r, 2*d.r))
+		i++
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+
+	"github.com/wlynxg/anet" // due to android sdk bugginess that exists for over 2 years
+)
+
+// DNS-SD over mDNS, just enough of RFC 6762/6763 to find each other
+const (
+	mdnsService   = "_ollama._tcp.local."
+	mdnsTTL       = 120
+	mdnsQU        = 1 << 15 // question: unicast response please
+	mdnsFlush     = 1 << 15 // answer: cache flush
+	mdnsMaxPacket = 9000
+)
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+func multicastInterfaces() []net.Interface {
+	ifaces, err := anet.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var out []net.Interface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagMulticast != 0 && iface.Flags&net.FlagLoopback == 0 {
+			out = append(out, iface)
+		}
+	}
+	return out
+}
+
+// Asks the LAN for _ollama._tcp and sends every host:port that answers
+// down the channel. Runs until ctx is done, then closes the channel.
+func browseMDNS(ctx context.Context) (<-chan string, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+	pc := ipv4.NewPacketConn(conn)
+	pc.SetMulticastTTL(255)
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(mdnsService),
+		Type:  dnsmessage.TypePTR,
+		Class: dnsmessage.ClassINET | mdnsQU,
+	})
+	query, err := b.Finish()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	found := make(chan string)
+	go func() {
+		<-ctx.Done()
+		conn.Close() // unblocks the reader
+	}()
+
+	// ask a few times, packets get lost and sleepy hosts wake up
+	go func() {
+		ifaces := multicastInterfaces()
+		for _, wait := range []time.Duration{0, time.Second, 3 * time.Second} {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+			if len(ifaces) == 0 {
+				pc.WriteTo(query, nil, mdnsGroup)
+			}
+			for _, iface := range ifaces {
+				if pc.SetMulticastInterface(&iface) == nil {
+					pc.WriteTo(query, nil, mdnsGroup)
+				}
+			}
+		}
+	}()
+
+	go func() {
+		defer close(found)
+
+		instances := map[string]bool{}
+		srvs := map[string]dnsmessage.SRVResource{}
+		addrs := map[string]net.IP{}
+		reported := map[string]bool{}
+		buf := make([]byte, mdnsMaxPacket)
+		for {
+			n, src, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return // closed
+			}
+			var m dnsmessage.Message
+			if m.Unpack(buf[:n]) != nil || !m.Header.Response {
+				continue
+			}
+
+			records := append(append(m.Answers, m.Authorities...), m.Additionals...)
+			for _, r := range records {
+				name := strings.ToLower(r.Header.Name.String())
+				switch body := r.Body.(type) {
+				case *dnsmessage.PTRResource:
+					if name == mdnsService {
+						instances[strings.ToLower(body.PTR.String())] = true
+					}
+				case *dnsmessage.SRVResource:
+					srvs[name] = *body
+				case *dnsmessage.AResource:
+					addrs[name] = net.IP(body.A[:])
+				}
+			}
+
+			for instance := range instances {
+				srv, ok := srvs[instance]
+				if !ok {
+					continue
+				}
+				ip, ok := addrs[strings.ToLower(srv.Target.String())]
+				if !ok {
+					ip = src.IP // whoever answered is a good guess
+				}
+				hostport := net.JoinHostPort(ip.String(), strconv.Itoa(int(srv.Port)))
+				if reported[hostport] {
+					continue
+				}
+				reported[hostport] = true
+				select {
+				case found <- hostport:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return found, nil
+}
+
+// everything the advertiser says about itself
+type mdnsAdvert struct {
+	instance dnsmessage.Name
+	target   dnsmessage.Name
+	port     uint16
+	ips      []net.IP
+}
+
+func newMDNSAdvert(hostport string) (*mdnsAdvert, error) {
+	host, portstr, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portstr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", portstr, err)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "ollama"
+	}
+	hostname, _, _ = strings.Cut(hostname, ".")
+
+	ad := &mdnsAdvert{port: uint16(port)}
+	ad.instance, err = dnsmessage.NewName(hostname + "." + mdnsService)
+	if err != nil {
+		return nil, err
+	}
+	ad.target, err = dnsmessage.NewName(hostname + ".local.")
+	if err != nil {
+		return nil, err
+	}
+
+	// 0.0.0.0:11434 and :11434 mean every address we have
+	ip := net.ParseIP(host)
+	switch {
+	case ip != nil && !ip.IsUnspecified():
+		if ip.To4() == nil {
+			return nil, errors.New("only ipv4 addresses can be advertised")
+		}
+		ad.ips = []net.IP{ip.To4()}
+		return ad, nil
+	case ip == nil && host != "":
+		// a name, the LAN gets what it resolves to
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve %q: %w", host, err)
+		}
+		for _, ip := range ips {
+			if ip.To4() != nil && !ip.IsLoopback() {
+				ad.ips = append(ad.ips, ip.To4())
+			}
+		}
+		if len(ad.ips) == 0 {
+			return nil, fmt.Errorf("%q has no ipv4 address other machines could reach", host)
+		}
+		return ad, nil
+	}
+	addrs, err := anet.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
+			ad.ips = append(ad.ips, ipnet.IP.To4())
+		}
+	}
+	if len(ad.ips) == 0 {
+		return nil, errors.New("no ipv4 address to advertise")
+	}
+	return ad, nil
+}
+
+// The full answer, ttl 0 says goodbye
+func (ad *mdnsAdvert) response(id uint16, questions []dnsmessage.Question, ttl uint32) ([]byte, error) {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, Response: true, Authoritative: true})
+	b.EnableCompression()
+	b.StartQuestions()
+	for _, q := range questions {
+		b.Question(q)
+	}
+
+	service := dnsmessage.MustNewName(mdnsService)
+	b.StartAnswers()
+	b.PTRResource(dnsmessage.ResourceHeader{Name: service, Class: dnsmessage.ClassINET, TTL: ttl},
+		dnsmessage.PTRResource{PTR: ad.instance})
+	b.SRVResource(dnsmessage.ResourceHeader{Name: ad.instance, Class: dnsmessage.ClassINET | mdnsFlush, TTL: ttl},
+		dnsmessage.SRVResource{Target: ad.target, Port: ad.port})
+	b.TXTResource(dnsmessage.ResourceHeader{Name: ad.instance, Class: dnsmessage.ClassINET | mdnsFlush, TTL: ttl},
+		dnsmessage.TXTResource{TXT: []string{"txtvers=1"}})
+
+	b.StartAdditionals()
+	for _, ip := range ad.ips {
+		var a dnsmessage.AResource
+		copy(a.A[:], ip)
+		b.AResource(dnsmessage.ResourceHeader{Name: ad.target, Class: dnsmessage.ClassINET | mdnsFlush, TTL: ttl}, a)
+	}
+	return b.Finish()
+}
+
+func (ad *mdnsAdvert) wants(q dnsmessage.Question) bool {
+	name := strings.ToLower(q.Name.String())
+	switch {
+	case name == mdnsService:
+		return q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL
+	case name == strings.ToLower(ad.instance.String()):
+		return q.Type == dnsmessage.TypeSRV || q.Type == dnsmessage.TypeTXT || q.Type == dnsmessage.TypeALL
+	case name == strings.ToLower(ad.target.String()):
+		return q.Type == dnsmessage.TypeA || q.Type == dnsmessage.TypeALL
+	}
+	return false
+}
+
+// Answers _ollama._tcp questions on the LAN for the ollama at hostport.
+// Meant to run next to ollama on the server, blocks until ctx is done.
+func advertiseMDNS(ctx context.Context, hostport string) error {
+	ad, err := newMDNSAdvert(hostport)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	pc := ipv4.NewPacketConn(conn)
+	pc.SetMulticastTTL(255)
+	for _, iface := range multicastInterfaces() {
+		pc.JoinGroup(&iface, mdnsGroup) // already joined on the default one
+	}
+
+	announce := func(ttl uint32) {
+		resp, err := ad.response(0, nil, ttl)
+		if err == nil {
+			conn.WriteToUDP(resp, mdnsGroup)
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		announce(0) // goodbye
+		conn.Close()
+	}()
+	announce(mdnsTTL)
+	time.AfterFunc(time.Second, func() { announce(mdnsTTL) })
+
+	fmt.Printf("advertising %s as %s on %v\n", hostport, ad.instance, ad.ips)
+
+	buf := make([]byte, mdnsMaxPacket)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		var m dnsmessage.Message
+		if m.Unpack(buf[:n]) != nil || m.Header.Response {
+			continue
+		}
+
+		var asked []dnsmessage.Question
+		unicast := false
+		for _, q := range m.Questions {
+			if ad.wants(q) {
+				asked = append(asked, q)
+				unicast = unicast || q.Class&mdnsQU != 0
+			}
+		}
+		if len(asked) == 0 {
+			continue
+		}
+
+		switch {
+		case src.Port != mdnsGroup.Port:
+			// legacy resolvers want their id and questions back
+			resp, err := ad.response(m.Header.ID, asked, mdnsTTL)
+			if err == nil {
+				conn.WriteToUDP(resp, src)
+			}
+		case unicast:
+			resp, err := ad.response(0, nil, mdnsTTL)
+			if err == nil {
+				conn.WriteToUDP(resp, src)
+			}
+		default:
+			announce(mdnsTTL)
+		}
+	}
+}
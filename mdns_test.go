@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMDNSAdvertAddresses(t *testing.T) {
+	ad, err := newMDNSAdvert("192.168.1.5:11434")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ad.ips) != 1 || !ad.ips[0].Equal(net.ParseIP("192.168.1.5")) || ad.port != 11434 {
+		t.Errorf("got %v port %d", ad.ips, ad.port)
+	}
+
+	for _, bad := range []string{
+		"localhost:11434", // resolves, but only to loopback
+		"[fe80::1]:11434",
+		"192.168.1.5",
+		"192.168.1.5:99999",
+	} {
+		if _, err := newMDNSAdvert(bad); err == nil {
+			t.Errorf("%s was accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// What a message can do, the names double as menu and select labels
+const (
+	actionNone       = "Nothing"
+	actionMenu       = "Open menu"
+	actionCopyPlain  = "Copy as plain text"
+	actionCopyMD     = "Copy as Markdown"
+	actionCopyCode   = "Copy code blocks"
+	actionEdit       = "Edit..."
+	actionDelete     = "Delete"
+	actionRegenerate = "Regenerate"
+	actionThink      = "Show thinking"
+	actionStats      = "Show stats"
+	actionStar       = "Star"
+	actionExport     = "Export..."
+)
+
+var gestureActions = []string{
+	actionNone, actionMenu, actionCopyPlain, actionCopyMD, actionCopyCode, actionEdit,
+	actionDelete, actionRegenerate, actionThink, actionStats, actionStar, actionExport,
+}
+
+// Which action each gesture on a message triggers
+type gestureSettings struct {
+	Tap       string `json:"tap"`
+	Secondary string `json:"secondary"` // right click, long press on mobile
+	Double    string `json:"double"`
+}
+
+// what the app always did, except long press opens the menu on
+// mobile since there is no mouse to hover the "..." button with
+func defaultGestures() gestureSettings {
+	gs := gestureSettings{Tap: actionThink, Secondary: actionCopyMD, Double: actionDelete}
+	if isMobile {
+		gs.Secondary = actionMenu
+	}
+	return gs
+}
+
+func (g *gui) loadGestures() {
+	g.gestures = defaultGestures()
+	s := g.a.Preferences().String("gestures")
+	if len(s) > 0 {
+		err := json.Unmarshal([]byte(s), &g.gestures)
+		if err != nil {
+			g.addStartfunc(func() { dialog.ShowError(fmt.Errorf("error loading gestures: %w", err), g.w) })
+		}
+	}
+	g.addSavefunc(func() {
+		b, err := json.Marshal(g.gestures)
+		if err != nil {
+			fmt.Printf("failed to save gestures: %s\n", err)
+			return
+		}
+		g.a.Preferences().SetString("gestures", string(b))
+	})
+}
+
+// The think part of a message, "" if there is none or it is not done yet
+func thinkingOf(content string) string {
+	message, found := strings.CutPrefix(strings.TrimSpace(content), "<think>")
+	if !found {
+		return ""
+	}
+	think, _, found := strings.Cut(message, "</think>")
+	if !found {
+		return ""
+	}
+	return strings.TrimSpace(think)
+}
+
+// The fenced code of a message, one block after the other
+func codeOf(content string) string {
+	var blocks []string
+	for _, p := range splitCodeFences(stripThinking(content)) {
+		if p.code {
+			blocks = append(blocks, p.text)
+		}
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// What the rich text would show, without the markdown around it
+func plainText(md string) string {
+	var sb strings.Builder
+	newline := func() {
+		if str := sb.String(); str != "" && !strings.HasSuffix(str, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	var walk func(segs []widget.RichTextSegment, prefix string)
+	walk = func(segs []widget.RichTextSegment, prefix string) {
+		for _, seg := range segs {
+			switch s := seg.(type) {
+			case *widget.ListSegment:
+				newline() // nested below the text of an item
+				for i, item := range s.Items {
+					if s.Ordered {
+						sb.WriteString(fmt.Sprintf("%s%d. ", prefix, i+1))
+					} else {
+						sb.WriteString(prefix + "- ")
+					}
+					walk([]widget.RichTextSegment{item}, prefix+"  ")
+				}
+				continue
+			case *widget.ParagraphSegment:
+				walk(s.Texts, prefix)
+			case *widget.SeparatorSegment:
+				sb.WriteString("\n")
+			default:
+				sb.WriteString(seg.Textual())
+			}
+			if !seg.Inline() {
+				newline()
+			}
+		}
+	}
+	walk(widget.NewRichTextFromMarkdown(md).Segments, "")
+	return strings.TrimSpace(sb.String())
+}
+
+// "### Assistant (llama3.2:3b)" and the content, for export
+func messageMarkdown(m chatMessage) string {
+	header := "### User"
+	if m.Role != "user" {
+		header = "### Assistant"
+	}
+	if label := modelLabel(m); label != "" {
+		header += " (" + label + ")"
+	}
+	return header + "\n\n" + stripThinking(strings.TrimSpace(m.Content)) + "\n"
+}
+
+// copies and says so on desktop, android has its own notification
+func (g *gui) clipboard(title, s string) {
+	g.a.Clipboard().SetContent(s)
+	if !isMobile {
+		g.goodEnoughDialog(title, s)
+	}
+}
+
+// The actions that make sense for m, grouped for the menu
+func messageActions(m chatMessage) [][]string {
+	copying := []string{actionCopyPlain, actionCopyMD}
+	if codeOf(m.Content) != "" {
+		copying = append(copying, actionCopyCode)
+	}
+	editing := []string{actionEdit, actionDelete}
+	if m.Role == "assistant" {
+		editing = append(editing, actionRegenerate)
+	}
+	info := []string{actionStats}
+	if thinkingOf(m.Content) != "" {
+		info = append([]string{actionThink}, info...)
+	}
+	return [][]string{copying, editing, info, {actionStar, actionExport}}
+}
+
+func actionIcon(action string) fyne.Resource {
+	switch action {
+	case actionCopyPlain, actionCopyMD, actionCopyCode:
+		return theme.ContentCopyIcon()
+	case actionEdit:
+		return theme.DocumentCreateIcon()
+	case actionDelete:
+		return theme.DeleteIcon()
+	case actionRegenerate:
+		return theme.ViewRefreshIcon()
+	case actionThink, actionStats:
+		return theme.InfoIcon()
+	case actionStar:
+		return theme.ConfirmIcon()
+	case actionExport:
+		return theme.DocumentSaveIcon()
+	}
+	return nil
+}
+
+// A number that stays with the message at index while others get
+// deleted or restored around it, handed out when first asked for.
+// Menus and dialogs hold on to it instead of the index.
+func (g *gui) messageID(index int) int {
+	if g.messages[index].ID == 0 {
+		g.lastid++
+		g.messages[index].ID = g.lastid
+	}
+	return g.messages[index].ID
+}
+
+// where the message with id is now, -1 if it is gone
+func (g *gui) messageIndex(id int) int {
+	return slices.IndexFunc(g.messages, func(m chatMessage) bool { return m.ID == id })
+}
+
+func (g *gui) messageMenu(id int) *fyne.Menu {
+	index := g.messageIndex(id)
+	if index < 0 {
+		return fyne.NewMenu("")
+	}
+	m := g.messages[index]
+	var items []*fyne.MenuItem
+	for i, group := range messageActions(m) {
+		if i > 0 {
+			items = append(items, fyne.NewMenuItemSeparator())
+		}
+		for _, action := range group {
+			item := fyne.NewMenuItem(action, func() { g.messageAction(action, id) })
+			item.Icon = actionIcon(action)
+			switch {
+			case action == actionRegenerate:
+				item.Disabled = g.stopGenerating != nil
+			case action == actionStar && m.Starred:
+				item.Label = "Unstar"
+			}
+			items = append(items, item)
+		}
+	}
+	return fyne.NewMenu("", items...)
+}
+
+// Wraps a rendered message with its gestures and the "..." menu
+func (g *gui) messageTappler(body fyne.CanvasObject, index int, markdown bool) *tappler {
+	id := g.messageID(index)
+	var t *tappler
+	gesture := func(action string) func(*fyne.PointEvent) {
+		if action == actionNone || action == "" {
+			return nil
+		}
+		if markdown && action == actionThink && g.gestures.Tap == actionThink {
+			return nil // markdown has its "Show Think" link for that, taps select text
+		}
+		return func(pe *fyne.PointEvent) {
+			if action == actionMenu {
+				t.ShowMenuAt(pe.AbsolutePosition)
+				return
+			}
+			g.messageAction(action, id)
+		}
+	}
+	t = NewTapperLayer(body, gesture(g.gestures.Tap), gesture(g.gestures.Secondary), gesture(g.gestures.Double))
+	t.SetMenu(func() *fyne.Menu { return g.messageMenu(id) })
+	return t
+}
+
+func (g *gui) messageAction(action string, id int) {
+	index := g.messageIndex(id)
+	if index < 0 {
+		return // deleted in the meantime
+	}
+	m := g.messages[index]
+	switch action {
+	case actionCopyPlain:
+		g.clipboard("Message Clipboarded", plainText(stripThinking(strings.TrimSpace(m.Content))))
+	case actionCopyMD:
+		g.clipboard("Message Clipboarded", strings.TrimSpace(m.Content))
+	case actionCopyCode:
+		if code := codeOf(m.Content); code != "" {
+			g.clipboard("Code Clipboarded", code)
+		}
+	case actionEdit:
+		g.editMessage(id)
+	case actionDelete:
+		// no confirmation, the snackbar and the trash can undo it
+		g.deleteMessage(index)
+	case actionRegenerate:
+		if m.Role == "assistant" {
+			g.confirmModelSwitch(index, func() { g.regenerate(index) })
+		}
+	case actionThink:
+		if think := thinkingOf(m.Content); think != "" {
+			g.goodEnoughDialog("Think Text", think)
+		}
+	case actionStats:
+		g.goodEnoughDialog("Message Stats", messageStats(m))
+	case actionStar:
+		g.messages[index].Starred = !m.Starred
+		g.msgscroller.RefreshCurrent()
+	case actionExport:
+		d := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, g.w)
+				return
+			}
+			if w == nil {
+				return // cancelled
+			}
+			defer w.Close()
+			_, err = w.Write([]byte(messageMarkdown(m)))
+			if err != nil {
+				dialog.ShowError(err, g.w)
+			}
+		}, g.w)
+		d.SetFileName("message.md")
+		d.Show()
+	}
+}
+
+// What we know about a message, one fact per line
+func messageStats(m chatMessage) string {
+	lines := []string{"Role: " + m.Role}
+	if m.Model != "" {
+		lines = append(lines, "Model: "+m.Model)
+	}
+	if m.Digest != "" {
+		lines = append(lines, "Digest: "+m.Digest)
+	}
+	if m.Server != "" {
+		lines = append(lines, "Server: "+m.Server)
+	}
+	content := stripThinking(strings.TrimSpace(m.Content))
+	lines = append(lines, fmt.Sprintf("Length: %d characters, %d words", len([]rune(content)), len(strings.Fields(content))))
+	if think := thinkingOf(m.Content); think != "" {
+		lines = append(lines, fmt.Sprintf("Thinking: %d words", len(strings.Fields(think))))
+	}
+	if m.Stats != nil {
+		if s := responseStats(*m.Stats); s != "" {
+			lines = append(lines, "Generation: "+s)
+		}
+		if m.Stats.PromptEvalCount > 0 {
+			lines = append(lines, fmt.Sprintf("Prompt: %d tokens", m.Stats.PromptEvalCount))
+		}
+	}
+	if len(m.Sources) > 0 {
+		lines = append(lines, "Sources: "+sourcesText(m.Sources))
+	}
+	if m.Error != "" {
+		lines = append(lines, "Error: "+m.Error)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Lets the user rewrite a message in place
+func (g *gui) editMessage(id int) {
+	index := g.messageIndex(id)
+	if index < 0 {
+		return
+	}
+	if g.stopGenerating != nil && index == len(g.messages)-1 {
+		dialog.ShowInformation("Edit", "Wait for the answer to finish first.", g.w)
+		return
+	}
+	entry := widget.NewMultiLineEntry()
+	entry.Wrapping = fyne.TextWrapWord
+	entry.SetText(g.messages[index].Content)
+	entry.SetMinRowsVisible(10)
+	d := dialog.NewCustomConfirm("Edit Message", "Save", "Cancel", entry, func(ok bool) {
+		// the message might have moved or be gone by now
+		index := g.messageIndex(id)
+		if !ok || index < 0 {
+			return
+		}
+		g.messages[index].Content = entry.Text
+		g.msgscroller.RefreshCurrent()
+	}, g.w)
+	d.Resize(fyne.NewSquareSize(500))
+	d.Show()
+}
+
+// Lets the user pick what tapping a message does
+func (g *gui) gesturesWidget() fyne.CanvasObject {
+	return widget.NewButton("Gestures", func() {
+		w := g.a.NewWindow("Gestures")
+
+		tap := widget.NewSelect(gestureActions, nil)
+		tap.SetSelected(g.gestures.Tap)
+		secondary := widget.NewSelect(gestureActions, nil)
+		secondary.SetSelected(g.gestures.Secondary)
+		double := widget.NewSelect(gestureActions, nil)
+		double.SetSelected(g.gestures.Double)
+
+		form := widget.NewForm(
+			widget.NewFormItem("Tap", tap),
+			widget.NewFormItem("Right click / long press", secondary),
+			widget.NewFormItem("Double tap", double),
+		)
+		form.SubmitText = "Save"
+		form.OnSubmit = func() {
+			g.gestures = gestureSettings{Tap: tap.Selected, Secondary: secondary.Selected, Double: double.Selected}
+			g.msgscroller.RefreshCurrent()
+			w.Close()
+		}
+		form.OnCancel = func() { w.Close() }
+
+		reset := widget.NewButton("Defaults", func() {
+			d := defaultGestures()
+			tap.SetSelected(d.Tap)
+			secondary.SetSelected(d.Secondary)
+			double.SetSelected(d.Double)
+		})
+
+		w.SetContent(container.NewBorder(
+			widget.NewLabel("Every action is also in the \"...\" menu of a message"), reset, nil, nil,
+			form,
+		))
+		w.Resize(fyne.NewSize(440, 260))
+		w.Show()
+	})
+}
@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestThinkingOf(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"no thinking", ""},
+		{"<think>hmm</think>answer", "hmm"},
+		{"\n <think>\n hmm \n</think>\n\nanswer", "hmm"},
+		{"<think>still going", ""},
+		{"answer <think>hmm</think>", ""},
+	} {
+		if got := thinkingOf(tc.in); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"no code", ""},
+		{"a\n```go\nx := 1\n```\nb", "x := 1"},
+		{"```\none\n```\nand\n```sh\ntwo\n```", "one\n\ntwo"},
+		{"```\nstreaming", "streaming"},
+		{"<think>\n```\nplan\n```\n</think>\n```\ncode\n```", "code"},
+	} {
+		if got := codeOf(tc.in); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"**bold** and *it*", "bold and it"},
+		{"# Title\n\ntext", "Title\ntext"},
+		{"[link](http://example.com) and `code`", "link and code"},
+		{"- a\n- b", "- a\n- b"},
+		{"1. a\n2. b", "1. a\n2. b"},
+		{"- a\n  - b\n- c", "- a\n  - b\n- c"},
+		{"a\n\n---\n\nb", "a\n\nb"},
+		{"```go\nx := 1\n```", "x := 1"},
+	} {
+		if got := plainText(tc.in); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+)
+
+// One instruction out of a Modelfile, Name is lowercase
+type modelfileCommand struct {
+	Name string
+	Args string
+	Line int
+}
+
+var modelfileInstructions = []string{"from", "parameter", "template", "system", "adapter", "license", "message"}
+
+// The subset of the Modelfile syntax ollama understands: one instruction
+// per line, # comments and """ for multiple lines. Good enough to check
+// what the user typed before we send it off, the server has the last word.
+func parseModelfile(s string) ([]modelfileCommand, error) {
+	var cmds []modelfileCommand
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		start := i + 1
+
+		instruction, args, _ := strings.Cut(line, " ")
+		instruction = strings.ToLower(instruction)
+		args = strings.TrimSpace(args)
+		known := false
+		for _, in := range modelfileInstructions {
+			known = known || in == instruction
+		}
+		if !known {
+			return nil, fmt.Errorf("line %d: unknown instruction %q", start, instruction)
+		}
+
+		// PARAMETER and MESSAGE have a name before the quoted part
+		prefix := ""
+		if instruction == "parameter" || instruction == "message" {
+			name, rest, _ := strings.Cut(args, " ")
+			prefix, args = name+" ", strings.TrimSpace(rest)
+		}
+
+		switch {
+		case strings.HasPrefix(args, `"""`):
+			// like ollama, the value ends with the first line that ends in """
+			body := args
+			for len(body) < 6 || !strings.HasSuffix(body, `"""`) {
+				i++
+				if i >= len(lines) {
+					return nil, fmt.Errorf("line %d: missing closing \"\"\"", start)
+				}
+				body = strings.TrimSpace(body + "\n" + lines[i])
+			}
+			args = body[3 : len(body)-3]
+		case len(args) >= 2 && strings.HasPrefix(args, `"`) && strings.HasSuffix(args, `"`):
+			args = args[1 : len(args)-1]
+		}
+		args = prefix + args
+
+		if strings.TrimSpace(args) == "" || (prefix != "" && strings.TrimSpace(strings.TrimPrefix(args, prefix)) == "") {
+			return nil, fmt.Errorf("line %d: %s needs an argument", start, strings.ToUpper(instruction))
+		}
+		cmds = append(cmds, modelfileCommand{Name: instruction, Args: args, Line: start})
+	}
+	return cmds, nil
+}
+
+// Turns "name value" lines into request options, ollama does the typing
+func parseParameters(s string) (map[string]any, error) {
+	params := map[string][]string{}
+	for _, l := range splitLines(s) {
+		if strings.HasPrefix(l, "#") {
+			continue
+		}
+		l = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(l, "PARAMETER "), "parameter "))
+		name, value, _ := strings.Cut(l, " ")
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if value == "" {
+			return nil, fmt.Errorf("%s needs a value", name)
+		}
+		params[name] = append(params[name], value)
+	}
+	if len(params) == 0 {
+		return nil, nil
+	}
+	return api.FormatParams(params)
+}
+
+// What client.Create wants for the parsed Modelfile
+func modelfileRequest(model string, cmds []modelfileCommand) (*api.CreateRequest, error) {
+	req := &api.CreateRequest{Model: model}
+	params := map[string][]string{}
+	var licenses []string
+	for _, c := range cmds {
+		switch c.Name {
+		case "from":
+			if req.From != "" {
+				return nil, fmt.Errorf("line %d: only one FROM please", c.Line)
+			}
+			if strings.HasPrefix(c.Args, "/") || strings.HasPrefix(c.Args, ".") || strings.HasPrefix(c.Args, "~") {
+				return nil, fmt.Errorf("line %d: FROM needs a model name, files are not supported here", c.Line)
+			}
+			req.From = c.Args
+		case "parameter":
+			name, value, _ := strings.Cut(c.Args, " ")
+			params[name] = append(params[name], value)
+		case "template":
+			req.Template = c.Args
+		case "system":
+			req.System = c.Args
+		case "license":
+			licenses = append(licenses, c.Args)
+		case "message":
+			role, content, _ := strings.Cut(c.Args, " ")
+			switch role {
+			case "system", "user", "assistant":
+			default:
+				return nil, fmt.Errorf("line %d: unknown message role %q", c.Line, role)
+			}
+			req.Messages = append(req.Messages, api.Message{Role: role, Content: content})
+		case "adapter":
+			return nil, fmt.Errorf("line %d: ADAPTER needs local files, not supported here", c.Line)
+		}
+	}
+	if req.From == "" {
+		return nil, fmt.Errorf("a Modelfile needs a FROM")
+	}
+	if len(params) > 0 {
+		var err error
+		req.Parameters, err = api.FormatParams(params)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch len(licenses) {
+	case 0:
+	case 1:
+		req.License = licenses[0]
+	default:
+		req.License = licenses
+	}
+	return req, nil
+}
+
+// Points the FROM line at base, adds one if there is none
+func setModelfileFrom(modelfile, base string) string {
+	lines := strings.Split(modelfile, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(l)), "from ") {
+			lines[i] = "FROM " + base
+			return strings.Join(lines, "\n")
+		}
+	}
+	return "FROM " + base + "\n" + modelfile
+}
+
+// A value as ollama reads it back. There is no escaping in Modelfiles,
+// so a value with """ in it cannot be written at all.
+func quoteModelfile(s string) (string, error) {
+	if strings.Contains(s, `"""`) {
+		return "", fmt.Errorf(`a Modelfile cannot hold """, there is no way to escape it`)
+	}
+	if strings.ContainsAny(s, "\n\"") || strings.TrimSpace(s) != s {
+		return `"""` + s + `"""`, nil
+	}
+	return s, nil
+}
+
+// A Modelfile that bakes system prompt and parameters into base
+func conversationModelfile(base string, conv conversationSettings) (string, error) {
+	var sb strings.Builder
+	sb.WriteString("FROM " + base + "\n")
+	if conv.System != "" {
+		system, err := quoteModelfile(conv.System)
+		if err != nil {
+			return "", fmt.Errorf("system prompt: %w", err)
+		}
+		sb.WriteString("SYSTEM " + system + "\n")
+	}
+	for _, l := range splitLines(conv.Parameters) {
+		if !strings.HasPrefix(l, "#") {
+			sb.WriteString("PARAMETER " + strings.TrimPrefix(strings.TrimPrefix(l, "PARAMETER "), "parameter ") + "\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+const modelfileHints = `### Instructions
+- ` + "`FROM llama3.2`" + ` the base model, required
+- ` + "`SYSTEM \"\"\"...\"\"\"`" + ` the system prompt
+- ` + "`PARAMETER temperature 0.7`" + ` one per line
+- ` + "`TEMPLATE \"\"\"...\"\"\"`" + ` the prompt template
+- ` + "`MESSAGE user Hi`" + ` example conversation
+- ` + "`LICENSE \"\"\"...\"\"\"`" + `
+- ` + "`# comment`" + `
+
+### Common parameters
+- num_ctx, num_predict, temperature
+- top_k, top_p, min_p, repeat_penalty
+- seed, stop
+`
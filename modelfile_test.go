@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseModelfile(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want []modelfileCommand
+		err  string
+	}{
+		{"plain", "FROM llama3.2\n# comment\nparameter temperature 0.7",
+			[]modelfileCommand{{"from", "llama3.2", 1}, {"parameter", "temperature 0.7", 3}}, ""},
+		{"single quotes", `SYSTEM "be brief"`, []modelfileCommand{{"system", "be brief", 1}}, ""},
+		{"multiline", "SYSTEM \"\"\"one\ntwo\n\"\"\"\nFROM x",
+			[]modelfileCommand{{"system", "one\ntwo\n", 1}, {"from", "x", 4}}, ""},
+		{"quote before the end", `SYSTEM """say "hi""""`, []modelfileCommand{{"system", `say "hi"`, 1}}, ""},
+		{"message", `MESSAGE user """Hi there"""`, []modelfileCommand{{"message", "user Hi there", 1}}, ""},
+		{"windows line ends", "FROM a\r\nSYSTEM b", []modelfileCommand{{"from", "a", 1}, {"system", "b", 2}}, ""},
+		{"unknown", "FOO bar", nil, "unknown instruction"},
+		{"unclosed", "SYSTEM \"\"\"one\ntwo", nil, "missing closing"},
+		{"no argument", "FROM", nil, "needs an argument"},
+		{"no parameter value", "PARAMETER temperature", nil, "needs an argument"},
+	} {
+		got, err := parseModelfile(tc.in)
+		switch {
+		case tc.err != "":
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: got error %v, want %q", tc.name, err, tc.err)
+			}
+		case err != nil:
+			t.Errorf("%s: %s", tc.name, err)
+		case len(got) != len(tc.want):
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		default:
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("%s: got %v, want %v", tc.name, got[i], tc.want[i])
+				}
+			}
+		}
+	}
+}
+
+func TestQuoteModelfileRoundTrip(t *testing.T) {
+	for _, system := range []string{
+		"be brief",
+		"one\ntwo",
+		`say "hi"`,
+		`ends with a quote"`,
+		`"starts with one`,
+		" spaces around ",
+		"# not a comment",
+	} {
+		modelfile, err := conversationModelfile("llama3.2", conversationSettings{modelProfile: modelProfile{System: system}})
+		if err != nil {
+			t.Errorf("%q: %s", system, err)
+			continue
+		}
+		cmds, err := parseModelfile(modelfile)
+		if err != nil {
+			t.Errorf("%q: %s\n%s", system, err, modelfile)
+			continue
+		}
+		if len(cmds) != 2 || cmds[1].Name != "system" || cmds[1].Args != system {
+			t.Errorf("%q came back as %v", system, cmds)
+		}
+	}
+
+	// no escaping in Modelfiles, so this has to fail instead of breaking
+	_, err := conversationModelfile("llama3.2", conversationSettings{modelProfile: modelProfile{System: `def f():\n    """docstring"""`}})
+	if err == nil {
+		t.Error(`""" in the system prompt was accepted`)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ollama/ollama/api"
+)
+
+// Edit a Modelfile and create model name from it on the current server
+func (g *gui) modelfileEditor(name, modelfile string) {
+	w := g.a.NewWindow("Modelfile")
+	client := g.client
+	ctx, cancel := context.WithCancel(context.Background())
+	creating := false
+
+	modelname := widget.NewEntry()
+	modelname.PlaceHolder = "my-assistant"
+	modelname.SetText(name)
+	modelname.Validator = func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return errors.New("needs a name")
+		}
+		return nil
+	}
+
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+	progress := widget.NewProgressBar()
+	progress.Hide()
+
+	editor := widget.NewMultiLineEntry()
+	editor.TextStyle = fyne.TextStyle{Monospace: true}
+	editor.Wrapping = fyne.TextWrapOff
+	editor.SetText(modelfile)
+	editor.Validator = func(s string) error {
+		cmds, err := parseModelfile(s)
+		if err == nil {
+			_, err = modelfileRequest("check", cmds)
+		}
+		return err
+	}
+	// the validator only draws an icon, say what is wrong
+	editor.OnChanged = func(s string) {
+		if creating {
+			return
+		}
+		if err := editor.Validator(s); err != nil {
+			status.SetText(err.Error())
+		} else {
+			status.SetText("")
+		}
+	}
+
+	basedon := widget.NewSelect(nil, func(s string) {
+		editor.SetText(setModelfileFrom(editor.Text, s))
+	})
+	basedon.PlaceHolder = "Based on..."
+	go func() {
+		list, err := client.List(ctx)
+		if err != nil {
+			return // they can still type the FROM line
+		}
+		var names []string
+		for _, m := range list.Models {
+			names = append(names, m.Name)
+		}
+		fyne.Do(func() { basedon.SetOptions(names) })
+	}()
+
+	var create *widget.Button
+	create = widget.NewButtonWithIcon("Create", theme.ConfirmIcon(), func() {
+		if modelname.Validate() != nil || editor.Validate() != nil {
+			return
+		}
+		model := strings.TrimSpace(modelname.Text)
+		cmds, _ := parseModelfile(editor.Text)
+		req, _ := modelfileRequest(model, cmds)
+
+		creating = true
+		create.Disable()
+		progress.Show()
+		progress.SetValue(0)
+		status.SetText("Creating " + model + "...")
+		go func() {
+			err := client.Create(ctx, req, func(p api.ProgressResponse) error {
+				fyne.Do(func() {
+					status.SetText(p.Status)
+					if p.Total > 0 {
+						progress.Max = float64(p.Total)
+						progress.SetValue(float64(p.Completed))
+					}
+				})
+				return nil
+			})
+			if ctx.Err() != nil {
+				return // window is gone
+			}
+			fyne.Do(func() {
+				creating = false
+				create.Enable()
+				progress.Hide()
+				if err != nil {
+					status.SetText(fmt.Sprintf("Creating %s failed: %s", model, err))
+					return
+				}
+				status.SetText(model + " created")
+				g.refreshModels()
+			})
+		}()
+	})
+	create.Importance = widget.HighImportance
+
+	hints := widget.NewAccordion(widget.NewAccordionItem("Syntax", widget.NewRichTextFromMarkdown(modelfileHints)))
+
+	w.SetOnClosed(cancel)
+	w.SetContent(container.NewBorder(
+		container.NewVBox(
+			widget.NewForm(widget.NewFormItem("Name", modelname)),
+			basedon,
+		),
+		container.NewVBox(hints, status, progress, container.NewGridWithColumns(2,
+			widget.NewButton("Close", func() { w.Close() }),
+			create,
+		)),
+		nil, nil,
+		editor,
+	))
+	w.Resize(fyne.NewSize(560, 600))
+	w.Show()
+}
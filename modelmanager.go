@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/format"
+)
+
+// "llama 8.0B Q4_K_M, 4.9 GB, 3 days ago"
+func modelSummary(m api.ListModelResponse) string {
+	var parts []string
+	for _, s := range []string{m.Details.Family, m.Details.ParameterSize, m.Details.QuantizationLevel} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	s := strings.Join(parts, " ")
+	if s != "" {
+		s += ", "
+	}
+	return s + format.HumanBytes(m.Size) + ", " + format.HumanTimeLower(m.ModifiedAt, "unknown")
+}
+
+func capabilityNames(show *api.ShowResponse) string {
+	var caps []string
+	for _, c := range show.Capabilities {
+		caps = append(caps, c.String())
+	}
+	if len(caps) == 0 {
+		return "unknown"
+	}
+	return strings.Join(caps, ", ")
+}
+
+// monospace, read only and still selectable
+func codeView(s string) fyne.CanvasObject {
+	e := widget.NewMultiLineEntry()
+	e.TextStyle = fyne.TextStyle{Monospace: true}
+	e.Wrapping = fyne.TextWrapOff
+	e.SetText(s)
+	e.OnChanged = func(string) { e.SetText(s) } // Disable() would grey it out
+	return e
+}
+
+func (g *gui) modelManager() {
+	if g.modelmanager != nil {
+		g.modelmanager.RequestFocus()
+		return
+	}
+
+	w := g.a.NewWindow("Models")
+	g.modelmanager = w
+	client := g.client
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var models []api.ListModelResponse
+	selected := -1
+
+	status := widget.NewLabel("")
+	status.Importance = widget.LowImportance
+	details := container.NewStack(widget.NewLabel("Select a model"))
+
+	list := widget.NewList(
+		func() int { return len(models) },
+		func() fyne.CanvasObject {
+			return container.NewVBox(
+				widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+				widget.NewLabel(""),
+			)
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			box := o.(*fyne.Container)
+			box.Objects[0].(*widget.Label).SetText(models[id].Name)
+			box.Objects[1].(*widget.Label).SetText(modelSummary(models[id]))
+		},
+	)
+
+	var copybutton, deletebutton *widget.Button
+	var reload func()
+
+	showDetails := func(m api.ListModelResponse) {
+		details.Objects = []fyne.CanvasObject{widget.NewLabel("Loading " + m.Name + "...")}
+		details.Refresh()
+		go func() {
+			show, err := client.Show(ctx, &api.ShowRequest{Model: m.Name})
+			if ctx.Err() != nil {
+				return
+			}
+			fyne.Do(func() {
+				if selected < 0 || models[selected].Name != m.Name {
+					return // user moved on
+				}
+				if err != nil {
+					details.Objects = []fyne.CanvasObject{widget.NewLabel(err.Error())}
+					details.Refresh()
+					return
+				}
+				info := widget.NewForm(
+					widget.NewFormItem("Name", widget.NewLabel(m.Name)),
+					widget.NewFormItem("Family", widget.NewLabel(strings.Join(show.Details.Families, ", "))),
+					widget.NewFormItem("Parameters", widget.NewLabel(show.Details.ParameterSize)),
+					widget.NewFormItem("Quantization", widget.NewLabel(show.Details.QuantizationLevel)),
+					widget.NewFormItem("Format", widget.NewLabel(show.Details.Format)),
+					widget.NewFormItem("Size", widget.NewLabel(format.HumanBytes(m.Size))),
+					widget.NewFormItem("Modified", widget.NewLabel(m.ModifiedAt.Format("2006-01-02 15:04"))),
+					widget.NewFormItem("Digest", widget.NewLabel(m.Digest[:min(12, len(m.Digest))])),
+					widget.NewFormItem("Capabilities", widget.NewLabel(capabilityNames(show))),
+				)
+				details.Objects = []fyne.CanvasObject{container.NewAppTabs(
+					container.NewTabItem("Info", container.NewVScroll(info)),
+					container.NewTabItem("Modelfile", codeView(show.Modelfile)),
+					container.NewTabItem("Template", codeView(show.Template)),
+					container.NewTabItem("Parameters", codeView(show.Parameters)),
+					container.NewTabItem("System", codeView(show.System)),
+					container.NewTabItem("License", codeView(show.License)),
+				)}
+				details.Refresh()
+			})
+		}()
+	}
+
+	list.OnSelected = func(id widget.ListItemID) {
+		selected = id
+		copybutton.Enable()
+		deletebutton.Enable()
+		showDetails(models[id])
+	}
+
+	reload = func() {
+		status.SetText("Loading...")
+		go func() {
+			resp, err := client.List(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			fyne.Do(func() {
+				if err != nil {
+					status.SetText(err.Error())
+					return
+				}
+				status.SetText(fmt.Sprintf("%d models on %s", len(resp.Models), g.lastserver))
+				models = resp.Models
+				slices.SortFunc(models, func(a, b api.ListModelResponse) int { return strings.Compare(a.Name, b.Name) })
+				selected = -1
+				list.UnselectAll()
+				list.Refresh()
+				copybutton.Disable()
+				deletebutton.Disable()
+				details.Objects = []fyne.CanvasObject{widget.NewLabel("Select a model")}
+				details.Refresh()
+				g.refreshModels() // keep the top select in sync
+			})
+		}()
+	}
+
+	copybutton = widget.NewButtonWithIcon("Copy", theme.ContentCopyIcon(), func() {
+		source := models[selected].Name
+		destination := widget.NewEntry()
+		destination.SetText(source)
+		destination.Validator = func(s string) error {
+			if s = strings.TrimSpace(s); s == "" || fullModelName(s) == fullModelName(source) {
+				return fmt.Errorf("needs a new name")
+			}
+			return nil
+		}
+		dialog.ShowForm("Copy "+source, "Copy", "Cancel",
+			[]*widget.FormItem{widget.NewFormItem("New name", destination)},
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				req := &api.CopyRequest{Source: source, Destination: strings.TrimSpace(destination.Text)}
+				status.SetText("Copying " + source + "...")
+				go func() {
+					err := client.Copy(ctx, req)
+					fyne.Do(func() {
+						if err != nil {
+							status.SetText("")
+							dialog.ShowError(fmt.Errorf("copy %s: %w", source, err), w)
+							return
+						}
+						reload()
+					})
+				}()
+			}, w)
+	})
+	deletebutton = widget.NewButtonWithIcon("Delete", theme.DeleteIcon(), func() {
+		name := models[selected].Name
+		dialog.ShowConfirm("Delete "+name, "Remove "+name+" from "+g.lastserver+"?\nThis can not be undone.", func(ok bool) {
+			if !ok {
+				return
+			}
+			status.SetText("Deleting " + name + "...")
+			go func() {
+				err := client.Delete(ctx, &api.DeleteRequest{Model: name})
+				fyne.Do(func() {
+					if err != nil {
+						status.SetText("")
+						dialog.ShowError(fmt.Errorf("delete %s: %w", name, err), w)
+						return
+					}
+					if g.model == name {
+						g.modelselection.SetSelected("")
+					}
+					reload()
+				})
+			}()
+		}, w)
+	})
+	deletebutton.Importance = widget.DangerImportance
+	copybutton.Disable()
+	deletebutton.Disable()
+
+	newmodel := widget.NewButtonWithIcon("New", theme.DocumentCreateIcon(), func() {
+		base := g.model
+		if selected >= 0 {
+			base = models[selected].Name
+		}
+		g.modelfileEditor("", "FROM "+base+"\n")
+	})
+
+	toolbar := container.NewHBox(
+		widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), reload),
+		newmodel,
+		copybutton,
+		deletebutton,
+	)
+
+	split := container.NewHSplit(list, details)
+	split.Offset = 0.4
+
+	w.SetOnClosed(func() {
+		cancel()
+		g.modelmanager = nil
+	})
+	w.SetContent(container.NewBorder(toolbar, status, nil, nil, split))
+	w.Resize(fyne.NewSize(760, 520))
+	w.Show()
+	reload()
+}
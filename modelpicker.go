@@ -0,0 +1,341 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/format"
+)
+
+const maxRecentModels = 5
+
+// Remembered between restarts
+type pickerPrefs struct {
+	Favourites []string `json:"favourites,omitempty"`
+	Recent     []string `json:"recent,omitempty"` // newest first
+}
+
+// The top bar model chooser. A button showing the current model
+// that opens a searchable list grouped by family.
+type modelPicker struct {
+	widget.BaseWidget
+	button *widget.Button
+
+	models       []api.ListModelResponse
+	selected     string
+	PlaceHolder  string
+	OnChanged    func(string)
+	capabilities func(api.ListModelResponse) []string
+	prefs        pickerPrefs
+}
+
+func newModelPicker(changed func(string)) *modelPicker {
+	p := &modelPicker{OnChanged: changed}
+	p.button = widget.NewButtonWithIcon("", theme.MenuDropDownIcon(), p.open)
+	p.button.Alignment = widget.ButtonAlignLeading
+	p.button.IconPlacement = widget.ButtonIconTrailingText
+	p.ExtendBaseWidget(p)
+	p.update()
+	return p
+}
+
+func (p *modelPicker) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(p.button)
+}
+
+func (p *modelPicker) update() {
+	switch {
+	case p.selected != "":
+		p.button.SetText(p.selected)
+	case p.PlaceHolder != "":
+		p.button.SetText(p.PlaceHolder)
+	default:
+		p.button.SetText("(Select one)")
+	}
+}
+
+func (p *modelPicker) SetOptions(models []api.ListModelResponse) {
+	p.models = models
+	p.update()
+}
+
+func (p *modelPicker) SetSelected(name string) {
+	changed := name != p.selected
+	p.selected = name
+	p.update()
+	if name != "" && name != nomodel {
+		p.prefs.Recent = slices.DeleteFunc(p.prefs.Recent, func(s string) bool { return s == name })
+		p.prefs.Recent = slices.Insert(p.prefs.Recent, 0, name)
+		p.prefs.Recent = p.prefs.Recent[:min(len(p.prefs.Recent), maxRecentModels)]
+	}
+	if changed && p.OnChanged != nil {
+		p.OnChanged(name)
+	}
+}
+
+func (p *modelPicker) favourite(name string) bool {
+	return slices.Contains(p.prefs.Favourites, name)
+}
+
+func (p *modelPicker) toggleFavourite(name string) {
+	if p.favourite(name) {
+		p.prefs.Favourites = slices.DeleteFunc(p.prefs.Favourites, func(s string) bool { return s == name })
+	} else {
+		p.prefs.Favourites = append(p.prefs.Favourites, name)
+	}
+}
+
+// Only embeds, nothing to chat with
+func embeddingOnly(m api.ListModelResponse, caps []string) bool {
+	if len(caps) > 0 {
+		return slices.Contains(caps, "embedding") && !slices.Contains(caps, "completion")
+	}
+	// no show response yet, bert is the usual suspect
+	return slices.ContainsFunc(append(m.Details.Families, m.Details.Family), func(f string) bool {
+		return strings.Contains(f, "bert")
+	})
+}
+
+func (p *modelPicker) caps(m api.ListModelResponse) []string {
+	if p.capabilities == nil {
+		return nil
+	}
+	return p.capabilities(m)
+}
+
+// "8.0B Q4_K_M, 4.9 GB  [vision] [tools]"
+func (p *modelPicker) describe(m api.ListModelResponse) string {
+	var parts []string
+	for _, s := range []string{m.Details.ParameterSize, m.Details.QuantizationLevel} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	s := strings.Join(append(parts, format.HumanBytes(m.Size)), " ")
+	for _, c := range p.caps(m) {
+		if c != "completion" {
+			s += "  [" + c + "]"
+		}
+	}
+	return s
+}
+
+// one line in the list, either a group header or a model
+type pickerRow struct {
+	header string
+	model  api.ListModelResponse
+}
+
+func (p *modelPicker) rows(query string, embeddings bool) []pickerRow {
+	query = strings.ToLower(strings.TrimSpace(query))
+	var matching []api.ListModelResponse
+	for _, m := range p.models {
+		if !embeddings && embeddingOnly(m, p.caps(m)) {
+			continue
+		}
+		haystack := strings.ToLower(strings.Join([]string{m.Name, m.Details.Family,
+			m.Details.ParameterSize, m.Details.QuantizationLevel, strings.Join(p.caps(m), " ")}, " "))
+		if query == "" || strings.Contains(haystack, query) {
+			matching = append(matching, m)
+		}
+	}
+
+	var rows []pickerRow
+	group := func(header string, models []api.ListModelResponse) {
+		if len(models) == 0 {
+			return
+		}
+		rows = append(rows, pickerRow{header: header})
+		for _, m := range models {
+			rows = append(rows, pickerRow{model: m})
+		}
+	}
+	byName := func(names []string) []api.ListModelResponse {
+		var out []api.ListModelResponse
+		for _, n := range names {
+			i := slices.IndexFunc(matching, func(m api.ListModelResponse) bool { return m.Name == n })
+			if i >= 0 {
+				out = append(out, matching[i])
+			}
+		}
+		return out
+	}
+	group("Favourites", byName(p.prefs.Favourites))
+	group("Recent", byName(p.prefs.Recent))
+
+	families := map[string][]api.ListModelResponse{}
+	for _, m := range matching {
+		f := cmp.Or(m.Details.Family, "other")
+		families[f] = append(families[f], m)
+	}
+	names := make([]string, 0, len(families))
+	for f := range families {
+		names = append(names, f)
+	}
+	slices.Sort(names)
+	for _, f := range names {
+		models := families[f]
+		slices.SortFunc(models, func(a, b api.ListModelResponse) int { return strings.Compare(a.Name, b.Name) })
+		group(f, models)
+	}
+	return rows
+}
+
+func (p *modelPicker) open() {
+	c := fyne.CurrentApp().Driver().CanvasForObject(p)
+	if c == nil {
+		return
+	}
+	var popup *widget.PopUp
+
+	search := widget.NewEntry()
+	search.PlaceHolder = "Search models"
+	embeddings := widget.NewCheck("Embedding models", nil)
+
+	rows := p.rows("", false)
+	var list *widget.List
+	list = widget.NewList(
+		func() int { return len(rows) },
+		func() fyne.CanvasObject {
+			star := widget.NewButtonWithIcon("", theme.ContentAddIcon(), nil)
+			star.Importance = widget.LowImportance
+			title := widget.NewLabel("")
+			title.Truncation = fyne.TextTruncateEllipsis
+			details := widget.NewLabel("")
+			details.Importance = widget.LowImportance
+			details.Truncation = fyne.TextTruncateEllipsis
+			return container.NewBorder(nil, nil, star, nil, container.NewVBox(title, details))
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			row := rows[id]
+			box := o.(*fyne.Container)
+			labels := box.Objects[0].(*fyne.Container)
+			star := box.Objects[1].(*widget.Button)
+			title := labels.Objects[0].(*widget.Label)
+			details := labels.Objects[1].(*widget.Label)
+
+			if row.header != "" {
+				star.Hide()
+				title.TextStyle = fyne.TextStyle{Bold: true}
+				title.SetText(row.header)
+				details.Hide()
+				return
+			}
+			name := row.model.Name
+			star.Show()
+			if p.favourite(name) {
+				star.SetIcon(theme.ConfirmIcon())
+				star.Importance = widget.HighImportance
+			} else {
+				star.SetIcon(theme.ContentAddIcon())
+				star.Importance = widget.LowImportance
+			}
+			star.OnTapped = func() {
+				p.toggleFavourite(name)
+				rows = p.rows(search.Text, embeddings.Checked)
+				list.Refresh()
+			}
+			star.Refresh()
+			title.TextStyle = fyne.TextStyle{Bold: name == p.selected}
+			title.SetText(name)
+			details.SetText(p.describe(row.model))
+			details.Show()
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		list.UnselectAll()
+		if rows[id].header != "" {
+			return
+		}
+		popup.Hide()
+		p.SetSelected(rows[id].model.Name)
+	}
+
+	filter := func() {
+		rows = p.rows(search.Text, embeddings.Checked)
+		list.Refresh()
+		list.ScrollToTop()
+	}
+	search.OnChanged = func(string) { filter() }
+	embeddings.OnChanged = func(bool) { filter() }
+	// enter picks the first hit
+	search.OnSubmitted = func(string) {
+		i := slices.IndexFunc(rows, func(r pickerRow) bool { return r.header == "" })
+		if i >= 0 {
+			list.Select(i)
+		}
+	}
+
+	empty := widget.NewLabel("No models, pull one or refresh the list")
+	if len(p.models) > 0 {
+		empty.Hide()
+	}
+
+	popup = widget.NewModalPopUp(container.NewBorder(
+		container.NewBorder(nil, nil, nil, embeddings, search),
+		widget.NewButton("Close", func() { popup.Hide() }),
+		nil, nil,
+		container.NewStack(list, empty),
+	), c)
+	size := c.Size()
+	popup.Resize(fyne.NewSize(min(480, size.Width-theme.Padding()*4), size.Height*0.8))
+	popup.Show()
+	c.Focus(search)
+}
+
+func (g *gui) loadModelPicker() {
+	s := g.a.Preferences().String("modelpicker")
+	if len(s) > 0 {
+		err := json.Unmarshal([]byte(s), &g.modelselection.prefs)
+		if err != nil {
+			g.addStartfunc(func() { dialog.ShowError(fmt.Errorf("error loading model favourites: %w", err), g.w) })
+		}
+	}
+	g.addSavefunc(func() {
+		b, err := json.Marshal(g.modelselection.prefs)
+		if err != nil {
+			fmt.Printf("failed to save model favourites: %s\n", err)
+			return
+		}
+		g.a.Preferences().SetString("modelpicker", string(b))
+	})
+	g.modelselection.capabilities = func(m api.ListModelResponse) []string { return g.modelcaps[m.Digest] }
+}
+
+// Asks the server what the models can do, the list does not say.
+// Only once per digest, the answer does not change.
+func (g *gui) fetchCapabilities(client *api.Client, models []api.ListModelResponse) {
+	for _, m := range models {
+		var known bool
+		fyne.DoAndWait(func() { _, known = g.modelcaps[m.Digest] })
+		if known {
+			continue
+		}
+		show, err := client.Show(context.Background(), &api.ShowRequest{Model: m.Name})
+		if err != nil {
+			return // try again on the next refresh
+		}
+		caps := []string{}
+		for _, c := range show.Capabilities {
+			caps = append(caps, c.String())
+		}
+		// there is no capability for it yet, the template knows
+		if strings.Contains(show.Template, "<think>") || strings.Contains(show.Template, ".Thinking") {
+			caps = append(caps, "thinking")
+		}
+		fyne.Do(func() {
+			g.modelcaps[m.Digest] = caps
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2/dialog"
+
+	"github.com/ollama/ollama/api"
+)
+
+const nomodel = "NONE - refresh list"
+
+// Fetches the model list from the current server and fills the top select
+func (g *gui) refreshModels() {
+	list, err := g.client.List(context.Background())
+	if err != nil {
+		if g.lastserver != "" {
+			// dont pop the box on first starts
+			dialog.ShowError(fmt.Errorf("cant retrieve model list: %w", err), g.w)
+		}
+		g.modelselection.PlaceHolder = nomodel
+		g.modelselection.SetOptions(nil)
+		return
+	}
+
+	g.modelinfo = make(map[string]api.ListModelResponse, len(list.Models))
+	for _, m := range list.Models {
+		g.modelinfo[m.Name] = m
+	}
+	g.modelselection.PlaceHolder = ""
+	g.modelselection.SetOptions(list.Models)
+	go g.fetchCapabilities(g.client, list.Models)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"strconv"
+	"syscall"
+)
+
+const (
+	ndmsgLen = 12 // struct ndmsg
+	ndaDst   = 1  // NDA_DST
+)
+
+// The IPv6 link-local addresses the kernel already has neighbour entries
+// for. We cant ping the whole fe80::/64, but whoever talked to us recently
+// is in here.
+func linkLocalNeighbours() ([]netip.Addr, error) {
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_INET6)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []netip.Addr
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWNEIGH || len(m.Data) < ndmsgLen {
+			continue
+		}
+		ifindex := int(int32(binary.NativeEndian.Uint32(m.Data[4:8])))
+
+		attrs := m.Data[ndmsgLen:]
+		for len(attrs) >= syscall.SizeofRtAttr {
+			alen := int(binary.NativeEndian.Uint16(attrs[0:2]))
+			atype := binary.NativeEndian.Uint16(attrs[2:4])
+			if alen < syscall.SizeofRtAttr || alen > len(attrs) {
+				break
+			}
+			if atype == ndaDst {
+				addr, ok := netip.AddrFromSlice(attrs[syscall.SizeofRtAttr:alen])
+				if ok && addr.Is6() && addr.IsLinkLocalUnicast() {
+					// numeric zones work fine and spare us another
+					// netlink call that android does not allow
+					out = append(out, addr.WithZone(strconv.Itoa(ifindex)))
+				}
+			}
+			// attributes are 4 byte aligned
+			alen = (alen + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+			if alen > len(attrs) {
+				break
+			}
+			attrs = attrs[alen:]
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net/netip"
+)
+
+func linkLocalNeighbours() ([]netip.Addr, error) {
+	return nil, errors.New("ipv6 neighbour discovery is only supported on linux")
+}
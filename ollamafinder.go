@@ -2,110 +2,518 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"net"
-	"net/http"
 	"net/netip"
 	"net/url"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/ollama/ollama/api"
-
-	"github.com/wlynxg/anet" // due to android sdk bugginess that exists for over 2 years
+	"github.com/ollama/ollama/format"
 )
 
-func searchForHosts(hosts *fyne.Container, selected func(string)) {
-	setLabel := func(s string) {
-		fyne.Do(func() {
-			hosts.Objects[0].(*widget.Label).SetText(s)
-			hosts.Refresh()
-		})
+// A host we found at some point, remembered between restarts
+type knownHost struct {
+	Host      string    `json:"host"`
+	Name      string    `json:"name,omitempty"` // given by the user
+	Pinned    bool      `json:"pinned,omitempty"`
+	FirstSeen time.Time `json:"firstseen"`
+	LastSeen  time.Time `json:"lastseen"`
+	Version   string    `json:"version,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Reachable bool      `json:"reachable"`
+	// from the last time it answered
+	Latency    time.Duration `json:"latency,omitempty"`
+	Models     []string      `json:"models,omitempty"`
+	ModelsSize int64         `json:"modelssize,omitempty"`
+	Running    []string      `json:"running,omitempty"`
+	verified   bool          // answered during the current scan
+}
+
+func (kh knownHost) label() string {
+	name := kh.Host
+	if kh.Name != "" {
+		name = fmt.Sprintf("%s - %s", kh.Name, kh.Host)
+	}
+	details := []string{kh.Version}
+	if kh.Source != "" {
+		details = append(details, kh.Source)
 	}
-	appendObject := func(co fyne.CanvasObject) {
-		fyne.Do(func() {
-			hosts.Objects = append(hosts.Objects, co)
-			hosts.Refresh()
-		})
+	details = append(details, fmt.Sprintf("%d models", len(kh.Models)))
+	switch len(kh.Running) {
+	case 0:
+	case 1:
+		details = append(details, "loaded: "+kh.Running[0])
+	default:
+		// buttons dont wrap, the rest is in the details
+		details = append(details, fmt.Sprintf("loaded: %s +%d", kh.Running[0], len(kh.Running)-1))
 	}
+	if !kh.verified {
+		if kh.Reachable {
+			details = append(details, "seen "+format.HumanTimeLower(kh.LastSeen, "never"))
+		} else {
+			details = append(details, "unreachable")
+		}
+	}
+	return fmt.Sprintf("%s (%s)", name, strings.Join(details, ", "))
+}
+
+// One discovery for the whole app. The settings window only looks at it,
+// so closing and reopening the window does not start from scratch.
+// Everything but the counters belongs to the ui thread.
+type discovery struct {
+	cancel   context.CancelFunc // nil when idle
+	gen      int                // which scan the incoming results belong to
+	complete bool               // the last scan ran to the end
+	hosts    []knownHost
+	errors   []string
+	scanned  atomic.Int64
+	total    atomic.Int64
+	onChange func(hostsChanged bool) // nil when nobody looks
+}
 
-	found := findInstances()
+func (g *gui) loadKnownHosts() {
+	s := g.a.Preferences().String("knownhosts")
+	if len(s) > 0 {
+		err := json.Unmarshal([]byte(s), &g.discovery.hosts)
+		if err != nil {
+			g.addStartfunc(func() { dialog.ShowError(fmt.Errorf("error loading known hosts: %w", err), g.w) })
+		}
+	}
+	g.addSavefunc(func() {
+		b, err := json.Marshal(g.discovery.hosts)
+		if err != nil {
+			fmt.Printf("failed to save known hosts: %s\n", err)
+			return
+		}
+		g.a.Preferences().SetString("knownhosts", string(b))
+	})
+}
+
+func (d *discovery) running() bool { return d.cancel != nil }
+
+func (d *discovery) changed(hostsChanged bool) {
+	if d.onChange != nil {
+		d.onChange(hostsChanged)
+	}
+}
+
+func (d *discovery) find(host string) int {
+	return slices.IndexFunc(d.hosts, func(kh knownHost) bool { return kh.Host == host })
+}
+
+// remembers whoever answered
+func (d *discovery) seen(h hosterInfo) {
+	if h.err != nil {
+		if !slices.Contains(d.errors, h.err.Error()) {
+			d.errors = append(d.errors, h.err.Error())
+		}
+		return
+	}
+
+	now := time.Now()
+	i := d.find(h.url.Host)
+	if i < 0 {
+		d.hosts = append(d.hosts, knownHost{Host: h.url.Host, FirstSeen: now})
+		i = len(d.hosts) - 1
+	}
+	kh := &d.hosts[i]
+	kh.LastSeen = now
+	kh.Version = h.version
+	if h.source != "" || kh.Source == "" {
+		kh.Source = h.source
+	}
+	kh.Reachable = true
+	kh.verified = true
+	kh.Latency = h.latency
+	kh.Models = h.models
+	kh.ModelsSize = h.modelssize
+	kh.Running = h.running
+}
+
+// the expanded part of a host row
+func (kh knownHost) details() fyne.CanvasObject {
+	lines := []string{
+		fmt.Sprintf("Latency: %s", kh.Latency.Round(time.Millisecond/10)),
+		fmt.Sprintf("Models: %d, %s on disk", len(kh.Models), format.HumanBytes(kh.ModelsSize)),
+		"First seen: " + format.HumanTimeLower(kh.FirstSeen, "never"),
+		"Last seen: " + format.HumanTimeLower(kh.LastSeen, "never"),
+	}
+	if len(kh.Running) > 0 {
+		lines = append(lines, "Loaded: "+strings.Join(kh.Running, ", "))
+	}
+	if len(kh.Models) > 0 {
+		lines = append(lines, "Available: "+strings.Join(kh.Models, ", "))
+	}
+	l := widget.NewLabel(strings.Join(lines, "\n"))
+	l.Wrapping = fyne.TextWrapWord
+	return l
+}
+
+// Starts a scan unless one is running. The known hosts are probed
+// again along with it. fresh forgets the errors of the last one.
+// proxies are the ones the user set up by host, so those servers are
+// probed the way the chat would reach them
+func (d *discovery) start(settings scanSettings, proxies map[string]string, fresh bool) {
+	if d.running() {
+		return
+	}
+	if fresh {
+		d.errors = nil
+	}
+	d.gen++
+	gen := d.gen
+	d.complete = false
+	d.scanned.Store(0)
+	d.total.Store(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+
+	var known []string
+	for i := range d.hosts {
+		d.hosts[i].verified = false // everyone has to answer again
+		known = append(known, d.hosts[i].Host)
+	}
+	found := findInstances(ctx, settings, proxies, known, &d.scanned, &d.total)
+	finish := func() {
+		if d.gen != gen {
+			return // stale
+		}
+		d.complete = ctx.Err() == nil
+		if d.complete {
+			// everyone had their chance to answer
+			for i := range d.hosts {
+				d.hosts[i].Reachable = d.hosts[i].verified
+			}
+		}
+		cancel()
+		d.cancel = nil
+		d.changed(true)
+	}
+	go func() {
+		// probes finish way too often to redraw on every one
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fyne.Do(func() { d.changed(false) })
+			case h, ok := <-found:
+				if !ok {
+					fyne.Do(finish)
+					return
+				}
+				fyne.Do(func() {
+					if d.gen != gen {
+						return // stale
+					}
+					d.seen(h)
+					d.changed(true)
+				})
+			}
+		}
+	}()
+	d.changed(false)
+}
+
+func (d *discovery) stop() {
+	if d.cancel != nil {
+		d.cancel()
+		d.cancel = nil
+		d.gen++ // whatever still arrives is stale
+		d.changed(false)
+	}
+}
+
+func (d *discovery) status() string {
 	num := 0
-	for {
-		h, ok := <-found
-		if !ok { // we read all
-			setLabel((fmt.Sprintf("Done Scanning, found %d", num)))
-			break
+	for _, kh := range d.hosts {
+		if kh.verified {
+			num++
+		}
+	}
+	switch {
+	case d.running():
+		return fmt.Sprintf("Scanning %d/%d, found %d", d.scanned.Load(), d.total.Load(), num)
+	case d.complete:
+		return fmt.Sprintf("Done Scanning, found %d", num)
+	default:
+		return fmt.Sprintf("Scan stopped, found %d", num)
+	}
+}
+
+// pinned first, then whoever answered, then the most recently seen
+func (d *discovery) sorted() []knownHost {
+	return slices.SortedStableFunc(slices.Values(d.hosts), func(a, b knownHost) int {
+		switch {
+		case a.Pinned != b.Pinned:
+			if a.Pinned {
+				return -1
+			}
+			return 1
+		case a.verified != b.verified:
+			if a.verified {
+				return -1
+			}
+			return 1
 		}
-		if h.err != nil {
-			appendObject(widget.NewLabel(h.err.Error()))
-			continue
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+}
+
+// The host list in the settings window. Known hosts show up right away,
+// greyed out until the scan heard from them again.
+func (g *gui) discoveryWidget(w fyne.Window, selected func(string)) fyne.CanvasObject {
+	d := &g.discovery
+	status := widget.NewLabel("Trying to search LAN for ollama...")
+	progress := widget.NewProgressBar()
+	hosts := container.NewVBox()
+	expanded := map[string]bool{}
+
+	rescan := widget.NewButtonWithIcon("Rescan", theme.ViewRefreshIcon(), func() {
+		d.stop()
+		d.start(g.scansettings, g.allProxies(), true)
+	})
+
+	var rebuild func()
+	menuFor := func(kh knownHost) *fyne.Menu {
+		pin := "Pin"
+		if kh.Pinned {
+			pin = "Unpin"
+		}
+		return fyne.NewMenu("",
+			fyne.NewMenuItem(pin, func() {
+				if i := d.find(kh.Host); i >= 0 {
+					d.hosts[i].Pinned = !d.hosts[i].Pinned
+					rebuild()
+				}
+			}),
+			fyne.NewMenuItem("Rename...", func() {
+				name := widget.NewEntry()
+				name.SetText(kh.Name)
+				name.PlaceHolder = "Workstation under the desk"
+				dialog.ShowForm("Rename "+kh.Host, "Save", "Cancel",
+					[]*widget.FormItem{widget.NewFormItem("Name", name)},
+					func(ok bool) {
+						if i := d.find(kh.Host); ok && i >= 0 {
+							d.hosts[i].Name = name.Text
+							rebuild()
+						}
+					}, w)
+			}),
+			fyne.NewMenuItem("Forget", func() {
+				if i := d.find(kh.Host); i >= 0 {
+					d.hosts = slices.Delete(d.hosts, i, i+1)
+					rebuild()
+				}
+			}),
+		)
+	}
+
+	rebuild = func() {
+		hosts.Objects = nil
+		for _, e := range d.errors {
+			hosts.Add(widget.NewLabel(e))
 		}
+		for _, kh := range d.sorted() {
+			pick := widget.NewButtonWithIcon(kh.label(), theme.MoveUpIcon(), func() { selected(kh.Host) })
+			pick.Alignment = widget.ButtonAlignLeading
+			if !kh.verified {
+				pick.Disable()
+			}
+			var more *widget.Button
+			more = widget.NewButtonWithIcon("", theme.MoreVerticalIcon(), func() {
+				c := fyne.CurrentApp().Driver().CanvasForObject(more)
+				pos := fyne.CurrentApp().Driver().AbsolutePositionForObject(more)
+				widget.ShowPopUpMenuAtPosition(menuFor(kh), c, pos.AddXY(0, more.Size().Height))
+			})
+
+			details := kh.details()
+			expandicon := theme.MenuExpandIcon()
+			if expanded[kh.Host] {
+				expandicon = theme.MenuDropDownIcon()
+			} else {
+				details.Hide()
+			}
+			expand := widget.NewButtonWithIcon("", expandicon, func() {
+				expanded[kh.Host] = !expanded[kh.Host]
+				rebuild()
+			})
+			expand.Importance = widget.LowImportance
 
-		num++
-		appendObject(widget.NewButtonWithIcon(fmt.Sprintf("%s (%s)", h.url.Host, h.version), theme.MoveUpIcon(), func() { selected(h.url.Host) }))
+			hosts.Add(container.NewVBox(
+				container.NewBorder(nil, nil, expand, more, pick),
+				details,
+			))
+		}
+		hosts.Refresh()
 	}
+
+	d.onChange = func(hostsChanged bool) {
+		status.SetText(d.status())
+		if total := d.total.Load(); total > 0 {
+			progress.SetValue(float64(d.scanned.Load()) / float64(total))
+		}
+		if d.running() {
+			progress.Show()
+		} else {
+			progress.Hide()
+		}
+		if hostsChanged {
+			rebuild()
+		}
+	}
+
+	// reuse what we have unless the last scan got cut short
+	if !d.complete {
+		d.start(g.scansettings, g.allProxies(), false)
+	}
+	d.changed(true)
+
+	return container.NewBorder(
+		container.NewVBox(container.NewBorder(nil, nil, nil, rescan, status), progress),
+		nil, nil, nil,
+		container.NewVScroll(hosts),
+	)
+}
+
+// Stops scanning once the settings window is gone
+func (g *gui) closeDiscovery() {
+	g.discovery.onChange = nil
+	g.discovery.stop()
 }
 
 type hosterInfo struct {
-	url     url.URL
-	version string
-	err     error
+	url        url.URL
+	version    string
+	source     string // "" for the scan
+	latency    time.Duration
+	models     []string
+	modelssize int64
+	running    []string
+	err        error
 }
 
-// low iq implementation, but its fine
-func findInstances() <-chan hosterInfo {
+// Fills in what the host has to offer, errors just leave gaps
+func inspectServer(ctx context.Context, h hosterInfo, proxy string) hosterInfo {
+	sp := serverProfile{Host: h.url.Host, Proxy: proxy}
+	client := api.NewClient(sp.url(), sp.httpClient(5*time.Second))
+
+	start := time.Now()
+	_, err := client.Version(ctx)
+	if err == nil {
+		h.latency = time.Since(start)
+	}
+	list, err := client.List(ctx)
+	if err == nil {
+		for _, m := range list.Models {
+			h.models = append(h.models, m.Name)
+			h.modelssize += m.Size
+		}
+	}
+	running, err := client.ListRunning(ctx)
+	if err == nil {
+		for _, m := range running.Models {
+			h.running = append(h.running, m.Name)
+		}
+	}
+	return h
+}
+
+// how long we listen for mDNS answers
+const mdnsBrowseTime = 5 * time.Second
+
+// Asks via mDNS and scans the LAN as configured, at the same time. The
+// known hosts are probed first and dont count against the scan limits.
+// scanned and total count the probes of the scan.
+func findInstances(ctx context.Context, settings scanSettings, proxies map[string]string, known []string, scanned, total *atomic.Int64) <-chan hosterInfo {
 	var wg sync.WaitGroup
 	hosters := make(chan hosterInfo)
 
+	// mDNS and the scan can both find the same host
+	var seenlock sync.Mutex
+	seen := map[string]bool{}
+	report := func(h hosterInfo) {
+		seenlock.Lock()
+		dupe := seen[h.url.Host]
+		seen[h.url.Host] = true
+		seenlock.Unlock()
+		if dupe {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h = inspectServer(ctx, h, proxies[h.url.Host])
+			select {
+			case hosters <- h:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
 	go func() {
 		defer close(hosters)
 
-		// working around a 2 year old bug
-		addrs, err := anet.InterfaceAddrs()
+		// ask nicely first, it also finds servers on other ports and subnets
+		mdnsCTX, cancel := context.WithTimeout(ctx, mdnsBrowseTime)
+		defer cancel()
+		announced, err := browseMDNS(mdnsCTX)
+		if err == nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for hostport := range announced {
+					sp := serverProfile{Host: hostport, Proxy: proxies[hostport]}
+					version, err := testServer(ctx, sp)
+					if err == nil {
+						report(hosterInfo{url: *sp.url(), version: version, source: "mDNS"})
+					}
+				}
+			}()
+		}
+
+		local, err := localPrefixes()
 		if err != nil {
 			hosters <- hosterInfo{err: fmt.Errorf("fail 1: %s", err)}
-			return
+		}
+		var neighbours []netip.Addr
+		if settings.IPv6LinkLocal {
+			neighbours, err = linkLocalNeighbours()
+			if err != nil {
+				hosters <- hosterInfo{err: fmt.Errorf("ipv6: %s", err)}
+			}
+		}
+		targets, capped, err := scanTargets(settings, local, neighbours)
+		if err != nil {
+			hosters <- hosterInfo{err: err}
+		}
+		if capped > 0 {
+			hosters <- hosterInfo{err: fmt.Errorf("scan limited to %d hosts, %d skipped", settings.hostLimit(), capped)}
 		}
 
-		for _, address := range addrs {
-			// check the address type and if it is not a loopback the display it
-			ipnet, ok := address.(*net.IPNet)
-			if ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
-				cidrAddress := ipnet.String()
-				p, err := netip.ParsePrefix(cidrAddress)
-				if err != nil {
-					//fmt.Printf("invalid cidr: %s, error %v\n", cidrAddress, err)
-					continue
-				}
-				// 8.8.8.8/24 => 8.8.8.0/24
-				p = p.Masked()
-
-				addr := p.Addr()
-				for {
-					if !p.Contains(addr) {
-						break
-					}
-
-					wg.Add(1)
-					go func(addr netip.Addr) {
-						base := url.URL{
-							Scheme: "http",
-							Host:   addr.String() + ":11434",
-						}
-						version, err := testServer(&base)
-						if err == nil {
-							hosters <- hosterInfo{url: base, version: version}
-						}
-						wg.Done()
-					}(addr)
-					addr = addr.Next()
-				}
+		for _, t := range slices.Backward(known) {
+			if !slices.Contains(targets, t) {
+				targets = slices.Insert(targets, 0, t)
 			}
 		}
+		total.Store(int64(len(targets)))
+		probe := func(ctx context.Context, sp serverProfile) (string, error) {
+			sp.Proxy = proxies[sp.Host]
+			return testServer(ctx, sp)
+		}
+		for h := range runScan(ctx, targets, settings.MaxConcurrent, probe, func() { scanned.Add(1) }) {
+			report(h)
+		}
 
 		wg.Wait() // for close
 	}()
@@ -113,26 +521,13 @@ func findInstances() <-chan hosterInfo {
 	return hosters
 }
 
-func testServer(surl *url.URL) (string, error) {
-	cl := &http.Client{Timeout: 2000 * time.Millisecond}
-	fakeclient := api.NewClient(surl, cl)
+func testServer(ctx context.Context, sp serverProfile) (string, error) {
+	fakeclient := api.NewClient(sp.url(), sp.httpClient(2000*time.Millisecond))
 
-	version, err := fakeclient.Version(context.TODO())
+	version, err := fakeclient.Version(ctx)
 	if err != nil {
 		return "", err
 	} else {
 		return version, nil
 	}
 }
-
-func warmCacheForModel(model string, client *api.Client) {
-	req := &api.ChatRequest{
-		Model:     model,
-		Stream:    new(bool),
-		KeepAlive: &api.Duration{Duration: 30 * time.Minute},
-	}
-	respFunc := func(resp api.ChatResponse) error { return nil }
-
-	// knock knock
-	client.Chat(context.TODO(), req, respFunc)
-}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/format"
+)
+
+const pullRefresh = 250 * time.Millisecond
+
+type layerProgress struct {
+	digest           string
+	total, completed int64
+}
+
+// "llama3" shows up as "llama3:latest" in the list. The tag is after
+// the last /, a registry can have a port of its own.
+func fullModelName(name string) string {
+	model := name[strings.LastIndex(name, "/")+1:]
+	if !strings.Contains(model, ":") {
+		return name + ":latest"
+	}
+	return name
+}
+
+// "3.2 MB/s, 1m20s left", ETA only once we know enough
+func pullSpeed(speed float64, remaining int64) string {
+	if speed <= 0 {
+		return ""
+	}
+	s := format.HumanBytes(int64(speed)) + "/s"
+	if remaining > 0 {
+		eta := time.Duration(float64(remaining) / speed * float64(time.Second))
+		s += ", " + max(time.Second, eta.Round(time.Second)).String() + " left"
+	}
+	return s
+}
+
+func (g *gui) pullWindow() {
+	w := g.a.NewWindow("Pull model")
+	var cancel context.CancelFunc
+
+	name := widget.NewEntry()
+	name.PlaceHolder = "llama3.2:3b"
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+	speed := widget.NewLabel("")
+	speed.Importance = widget.LowImportance
+	layers := container.NewVBox()
+	bars := map[string]*widget.ProgressBar{}
+
+	var pull, stop *widget.Button
+	running := func(r bool) {
+		if r {
+			name.Disable()
+			pull.Disable()
+			stop.Enable()
+		} else {
+			name.Enable()
+			pull.Enable()
+			stop.Disable()
+			cancel = nil
+		}
+	}
+
+	// runs in the ui thread with a snapshot from the pull goroutine
+	update := func(st string, snapshot []layerProgress, s string) {
+		status.SetText(st)
+		speed.SetText(s)
+		for _, l := range snapshot {
+			bar, ok := bars[l.digest]
+			if !ok {
+				short := strings.TrimPrefix(l.digest, "sha256:")
+				short = short[:min(12, len(short))]
+				bar = widget.NewProgressBar()
+				bar.TextFormatter = func() string {
+					return fmt.Sprintf("%s %s/%s", short, format.HumanBytes(int64(bar.Value)), format.HumanBytes(int64(bar.Max)))
+				}
+				bars[l.digest] = bar
+				layers.Add(bar)
+			}
+			bar.Max = float64(max(l.total, 1))
+			bar.SetValue(float64(l.completed))
+		}
+	}
+
+	start := func() {
+		model := strings.TrimSpace(name.Text)
+		if model == "" || cancel != nil {
+			return
+		}
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		client := g.client
+		running(true)
+		bars = map[string]*widget.ProgressBar{}
+		layers.Objects = nil
+		layers.Refresh()
+		status.SetText("Pulling " + model + "...")
+		speed.SetText("")
+
+		go func() {
+			var order []string
+			progress := map[string]*layerProgress{}
+			var st string
+			var lastUpdate, lastSample time.Time
+			var lastBytes int64
+			var bytesPerSec float64
+
+			snapshot := func() (string, []layerProgress, string) {
+				var out []layerProgress
+				var done, total int64
+				for _, d := range order {
+					out = append(out, *progress[d])
+					done += progress[d].completed
+					total += progress[d].total
+				}
+				// smoothed so the eta does not jump around
+				now := time.Now()
+				if !lastSample.IsZero() {
+					secs := now.Sub(lastSample).Seconds()
+					if secs > 0 {
+						cur := float64(done-lastBytes) / secs
+						if bytesPerSec == 0 {
+							bytesPerSec = cur
+						} else {
+							bytesPerSec = 0.7*bytesPerSec + 0.3*cur
+						}
+					}
+				}
+				lastSample, lastBytes = now, done
+				return st, out, pullSpeed(bytesPerSec, total-done)
+			}
+
+			err := client.Pull(ctx, &api.PullRequest{Model: model}, func(p api.ProgressResponse) error {
+				statusChanged := p.Status != st
+				st = p.Status
+				if p.Digest != "" {
+					l, ok := progress[p.Digest]
+					if !ok {
+						l = &layerProgress{digest: p.Digest}
+						progress[p.Digest] = l
+						order = append(order, p.Digest)
+					}
+					l.total, l.completed = p.Total, p.Completed
+				}
+				if statusChanged || time.Since(lastUpdate) >= pullRefresh {
+					lastUpdate = time.Now()
+					s, snap, sp := snapshot()
+					fyne.Do(func() { update(s, snap, sp) })
+				}
+				return nil
+			})
+
+			fyne.Do(func() {
+				running(false)
+				speed.SetText("")
+				switch {
+				case errors.Is(err, context.Canceled):
+					status.SetText("Cancelled " + model)
+				case err != nil:
+					status.SetText(fmt.Sprintf("Pulling %s failed: %s", model, err))
+				default:
+					for _, bar := range bars {
+						bar.SetValue(bar.Max)
+					}
+					status.SetText(model + " is ready")
+					g.a.SendNotification(fyne.NewNotification("Model pulled", model+" is ready to chat"))
+					g.refreshModels()
+					g.modelselection.SetSelected(fullModelName(model))
+				}
+			})
+		}()
+	}
+
+	pull = widget.NewButtonWithIcon("Pull", theme.DownloadIcon(), start)
+	pull.Importance = widget.HighImportance
+	stop = widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), func() {
+		if cancel != nil {
+			cancel()
+		}
+	})
+	stop.Disable()
+	name.OnSubmitted = func(string) { start() }
+
+	w.SetOnClosed(func() {
+		if cancel != nil {
+			cancel()
+		}
+	})
+	w.SetContent(container.NewBorder(
+		container.NewVBox(
+			widget.NewLabel("Model name from ollama.com/library"),
+			container.NewBorder(nil, nil, nil, container.NewHBox(pull, stop), name),
+			status,
+			speed,
+		),
+		nil, nil, nil,
+		container.NewVScroll(layers),
+	))
+	w.Resize(fyne.NewSize(440, 360))
+	w.Show()
+}
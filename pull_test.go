@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestFullModelName(t *testing.T) {
+	for in, want := range map[string]string{
+		"llama3":                          "llama3:latest",
+		"llama3.2:3b":                     "llama3.2:3b",
+		"user/model":                      "user/model:latest",
+		"user/model:q4":                   "user/model:q4",
+		"registry.local:5000/user/model":  "registry.local:5000/user/model:latest",
+		"registry.local:5000/user/m:fp16": "registry.local:5000/user/m:fp16",
+	} {
+		if got := fullModelName(in); got != want {
+			t.Errorf("%s: got %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestPullSpeed(t *testing.T) {
+	for _, tc := range []struct {
+		speed     float64
+		remaining int64
+		want      string
+	}{
+		{0, 100, ""},
+		{1000, 0, "1 KB/s"},
+		{1000, 90_000, "1 KB/s, 1m30s left"},
+		// was truncated to 0s
+		{1000, 1_600, "1 KB/s, 2s left"},
+		{1000, 100, "1 KB/s, 1s left"},
+	} {
+		if got := pullSpeed(tc.speed, tc.remaining); got != tc.want {
+			t.Errorf("%v %d: got %q, want %q", tc.speed, tc.remaining, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/wlynxg/anet" // due to android sdk bugginess that exists for over 2 years
+)
+
+// What the LAN scan looks at
+type scanSettings struct {
+	Ports         []uint16 `json:"ports"`
+	ExtraCIDRs    []string `json:"extracidrs,omitempty"` // scanned on top of the local subnets
+	IPv6LinkLocal bool     `json:"ipv6linklocal,omitempty"`
+	MaxHosts      int      `json:"maxhosts"`      // addresses, not host:port pairs
+	MaxConcurrent int      `json:"maxconcurrent"` // probes in flight
+}
+
+// No matter what the settings say, a /8 would be millions of
+// addresses in memory before the first probe
+const maxScanHosts = 65536
+
+func defaultScanSettings() scanSettings {
+	return scanSettings{
+		Ports:         []uint16{11434},
+		MaxHosts:      1024,
+		MaxConcurrent: 64,
+	}
+}
+
+func (g *gui) loadScanSettings() {
+	g.scansettings = defaultScanSettings()
+	s := g.a.Preferences().String("scansettings")
+	if len(s) > 0 {
+		err := json.Unmarshal([]byte(s), &g.scansettings)
+		if err != nil {
+			g.addStartfunc(func() { dialog.ShowError(fmt.Errorf("error loading scan settings: %w", err), g.w) })
+		}
+	}
+	g.addSavefunc(func() {
+		b, err := json.Marshal(g.scansettings)
+		if err != nil {
+			fmt.Printf("failed to save scan settings: %s\n", err)
+			return
+		}
+		g.a.Preferences().SetString("scansettings", string(b))
+	})
+}
+
+// The ipv4 subnets we sit in, 192.168.1.23/24 => 192.168.1.0/24
+func localPrefixes() ([]netip.Prefix, error) {
+	// working around a 2 year old bug
+	addrs, err := anet.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var prefixes []netip.Prefix
+	for _, address := range addrs {
+		ipnet, ok := address.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() || ipnet.IP.To4() == nil {
+			continue
+		}
+		p, err := netip.ParsePrefix(ipnet.String())
+		if err != nil {
+			continue
+		}
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()).Masked()
+		if !slices.Contains(prefixes, p) {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes, nil
+}
+
+// MaxHosts, with 0 or anything too big meaning maxScanHosts
+func (s scanSettings) hostLimit() int {
+	if s.MaxHosts <= 0 || s.MaxHosts > maxScanHosts {
+		return maxScanHosts
+	}
+	return s.MaxHosts
+}
+
+// Every host:port the scan should probe, in order, and how many addresses
+// MaxHosts left out. Pure, so it can be tested without a network.
+func scanTargets(settings scanSettings, local []netip.Prefix, neighbours []netip.Addr) ([]string, int, error) {
+	prefixes := slices.Clone(local)
+	for _, c := range settings.ExtraCIDRs {
+		p, err := netip.ParsePrefix(strings.TrimSpace(c))
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid cidr %q: %w", c, err)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+
+	var addrs []netip.Addr
+	seen := map[netip.Addr]bool{}
+	full := func() bool { return len(addrs) >= settings.hostLimit() }
+	add := func(a netip.Addr) {
+		if !seen[a] && !full() {
+			seen[a] = true
+			addrs = append(addrs, a)
+		}
+	}
+
+	// total is what we would have scanned without the cap
+	total := 0
+	for _, p := range prefixes {
+		// network and broadcast address are no hosts
+		first, last := p.Addr(), lastAddr(p)
+		skipEnds := p.Addr().Is4() && p.Bits() < 31
+		total += prefixSize(p)
+		if skipEnds {
+			total -= 2
+		}
+		for a := first; p.Contains(a) && !full(); a = a.Next() {
+			if !skipEnds || (a != first && a != last) {
+				add(a)
+			}
+		}
+	}
+	if settings.IPv6LinkLocal {
+		total += len(neighbours)
+		for _, n := range neighbours {
+			add(n)
+		}
+	}
+
+	ports := settings.Ports
+	if len(ports) == 0 {
+		ports = defaultScanSettings().Ports
+	}
+	targets := make([]string, 0, len(addrs)*len(ports))
+	for _, a := range addrs {
+		for _, port := range ports {
+			targets = append(targets, netip.AddrPortFrom(a, port).String())
+		}
+	}
+	return targets, max(0, total-len(addrs)), nil
+}
+
+// the broadcast address for ipv4
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a.WithZone(p.Addr().Zone())
+}
+
+// capped so ipv6 prefixes dont overflow, nobody scans those anyway
+func prefixSize(p netip.Prefix) int {
+	hostbits := p.Addr().BitLen() - p.Bits()
+	if hostbits > 30 {
+		return 1 << 30
+	}
+	return 1 << hostbits
+}
+
+type probeFunc func(ctx context.Context, sp serverProfile) (string, error)
+
+// Probes targets with at most workers probes in flight and reports every
+// host that answers. onProbed, if set, is called after every probe from
+// the workers. The channel closes once everything was probed or ctx is done.
+func runScan(ctx context.Context, targets []string, workers int, probe probeFunc, onProbed func()) <-chan hosterInfo {
+	found := make(chan hosterInfo)
+	jobs := make(chan string)
+	workers = max(1, min(workers, len(targets)))
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for hostport := range jobs {
+				sp := serverProfile{Host: hostport}
+				version, err := probe(ctx, sp)
+				if onProbed != nil {
+					onProbed()
+				}
+				if err != nil {
+					continue
+				}
+				select {
+				case found <- hosterInfo{url: *sp.url(), version: version}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, t := range targets {
+			select {
+			case jobs <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	return found
+}
+
+func parsePorts(s string) ([]uint16, error) {
+	var ports []uint16
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		p, err := strconv.ParseUint(f, 10, 16)
+		if err != nil || p == 0 {
+			return nil, fmt.Errorf("invalid port %q", f)
+		}
+		ports = append(ports, uint16(p))
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("need at least one port")
+	}
+	return ports, nil
+}
+
+func formatPorts(ports []uint16) string {
+	var s []string
+	for _, p := range ports {
+		s = append(s, strconv.Itoa(int(p)))
+	}
+	return strings.Join(s, ", ")
+}
+
+func splitLines(s string) []string {
+	var out []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+func validateCIDRs(s string) error {
+	for _, l := range splitLines(s) {
+		_, err := netip.ParsePrefix(l)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePositive(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return fmt.Errorf("needs to be a number above 0")
+	}
+	return nil
+}
+
+func (g *gui) scanSettingsWidget() fyne.CanvasObject {
+	return widget.NewButton("LAN Scan", func() {
+		w := g.a.NewWindow("LAN Scan")
+
+		ports := widget.NewEntry()
+		ports.SetText(formatPorts(g.scansettings.Ports))
+		ports.Validator = func(s string) error { _, err := parsePorts(s); return err }
+
+		cidrs := widget.NewMultiLineEntry()
+		cidrs.PlaceHolder = "10.0.0.0/16\n192.168.178.0/24"
+		cidrs.SetText(strings.Join(g.scansettings.ExtraCIDRs, "\n"))
+		cidrs.Validator = validateCIDRs
+		cidrs.SetMinRowsVisible(3)
+
+		ipv6 := widget.NewCheck("Probe known IPv6 link-local neighbours", nil)
+		ipv6.SetChecked(g.scansettings.IPv6LinkLocal)
+
+		maxhosts := widget.NewEntry()
+		maxhosts.SetText(strconv.Itoa(g.scansettings.MaxHosts))
+		maxhosts.Validator = func(s string) error {
+			if n, _ := strconv.Atoi(s); n > maxScanHosts {
+				return fmt.Errorf("at most %d", maxScanHosts)
+			}
+			return validatePositive(s)
+		}
+		maxconcurrent := widget.NewEntry()
+		maxconcurrent.SetText(strconv.Itoa(g.scansettings.MaxConcurrent))
+		maxconcurrent.Validator = validatePositive
+
+		form := widget.NewForm(
+			widget.NewFormItem("Ports", ports),
+			widget.NewFormItem("Extra CIDRs", cidrs),
+			widget.NewFormItem("", ipv6),
+			widget.NewFormItem("Max hosts", maxhosts),
+			widget.NewFormItem("Max probes", maxconcurrent),
+		)
+		form.SubmitText = "Save"
+		form.OnSubmit = func() {
+			// the validators already had their say
+			g.scansettings.Ports, _ = parsePorts(ports.Text)
+			g.scansettings.ExtraCIDRs = splitLines(cidrs.Text)
+			g.scansettings.IPv6LinkLocal = ipv6.Checked
+			g.scansettings.MaxHosts, _ = strconv.Atoi(maxhosts.Text)
+			g.scansettings.MaxConcurrent, _ = strconv.Atoi(maxconcurrent.Text)
+			w.Close()
+		}
+		form.OnCancel = func() { w.Close() }
+
+		w.SetContent(container.NewBorder(
+			widget.NewLabel("Used by the next scan"), nil, nil, nil,
+			form,
+		))
+		w.Resize(fyne.NewSize(440, 360))
+		w.Show()
+	})
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScanTargetsMaxHosts(t *testing.T) {
+	settings := scanSettings{Ports: []uint16{11434}, MaxHosts: 10}
+	local := []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24")}
+
+	targets, capped, err := scanTargets(settings, local, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 10 {
+		t.Fatalf("got %d targets, want 10", len(targets))
+	}
+	// network and broadcast are no hosts, so 254 minus the 10 we scan
+	if capped != 244 {
+		t.Errorf("capped = %d, want 244", capped)
+	}
+	if targets[0] != "192.168.1.1:11434" || targets[9] != "192.168.1.10:11434" {
+		t.Errorf("unexpected targets %v", targets)
+	}
+
+	// no cap set means the hard ceiling, which a /24 does not reach
+	settings.MaxHosts = 0
+	targets, capped, err = scanTargets(settings, local, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 254 || capped != 0 {
+		t.Errorf("got %d targets and %d capped, want 254 and 0", len(targets), capped)
+	}
+}
+
+func TestScanTargetsCeiling(t *testing.T) {
+	local := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	for _, maxhosts := range []int{0, -1, maxScanHosts * 4} {
+		settings := scanSettings{Ports: []uint16{11434}, MaxHosts: maxhosts}
+		targets, capped, err := scanTargets(settings, local, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(targets) != maxScanHosts || capped != 1<<24-2-maxScanHosts {
+			t.Errorf("MaxHosts %d: got %d targets and %d capped, want %d and %d",
+				maxhosts, len(targets), capped, maxScanHosts, 1<<24-2-maxScanHosts)
+		}
+	}
+}
+
+func TestScanTargetsSmallPrefixes(t *testing.T) {
+	settings := scanSettings{Ports: []uint16{11434}, MaxHosts: 100}
+	for _, tc := range []struct {
+		prefix string
+		want   []string
+	}{
+		// point to point links have no network and broadcast address
+		{"10.0.0.0/31", []string{"10.0.0.0:11434", "10.0.0.1:11434"}},
+		{"10.0.0.5/32", []string{"10.0.0.5:11434"}},
+		{"10.0.0.0/30", []string{"10.0.0.1:11434", "10.0.0.2:11434"}},
+	} {
+		targets, capped, err := scanTargets(settings, []netip.Prefix{netip.MustParsePrefix(tc.prefix)}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(targets, tc.want) || capped != 0 {
+			t.Errorf("%s: got %v and %d capped, want %v", tc.prefix, targets, capped, tc.want)
+		}
+	}
+}
+
+func TestScanTargetsExtraCIDRsAndPorts(t *testing.T) {
+	settings := scanSettings{
+		Ports:      []uint16{11434, 8080},
+		ExtraCIDRs: []string{" 172.16.0.0/30 "},
+		MaxHosts:   100,
+	}
+	local := []netip.Prefix{netip.MustParsePrefix("10.0.0.4/32")}
+
+	targets, _, err := scanTargets(settings, local, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"10.0.0.4:11434", "10.0.0.4:8080",
+		"172.16.0.1:11434", "172.16.0.1:8080",
+		"172.16.0.2:11434", "172.16.0.2:8080",
+	}
+	if !slices.Equal(targets, want) {
+		t.Errorf("got %v, want %v", targets, want)
+	}
+
+	// the cap counts addresses, not host:port pairs
+	settings.MaxHosts = 2
+	targets, capped, err := scanTargets(settings, local, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 4 || capped != 1 {
+		t.Errorf("got %d targets and %d capped, want 4 and 1", len(targets), capped)
+	}
+
+	settings.ExtraCIDRs = []string{"not a cidr"}
+	_, _, err = scanTargets(settings, local, nil)
+	if err == nil {
+		t.Error("invalid cidr was accepted")
+	}
+}
+
+func TestScanTargetsLinkLocal(t *testing.T) {
+	neighbours := []netip.Addr{netip.MustParseAddr("fe80::1%eth0"), netip.MustParseAddr("fe80::2%wlan0")}
+	settings := scanSettings{Ports: []uint16{11434}, MaxHosts: 100}
+
+	targets, _, err := scanTargets(settings, nil, neighbours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 0 {
+		t.Errorf("neighbours scanned while disabled: %v", targets)
+	}
+
+	settings.IPv6LinkLocal = true
+	targets, _, err = scanTargets(settings, nil, neighbours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"[fe80::1%eth0]:11434", "[fe80::2%wlan0]:11434"}
+	if !slices.Equal(targets, want) {
+		t.Errorf("got %v, want %v", targets, want)
+	}
+}
+
+// an ollama that only knows its version
+func fakeOllama(t *testing.T, version string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/version" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"version":%q}`, version)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// an address where nobody listens
+func closedPort(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestRunScanFindsListeners(t *testing.T) {
+	var want []string
+	var targets []string
+	for i := range 3 {
+		srv := fakeOllama(t, fmt.Sprintf("0.6.%d", i))
+		host := srv.Listener.Addr().String()
+		want = append(want, host)
+		targets = append(targets, host, closedPort(t))
+	}
+
+	var probed atomic.Int64
+	found := runScan(context.Background(), targets, 2, testServer, func() { probed.Add(1) })
+
+	var got []string
+	for h := range found {
+		if h.version == "" {
+			t.Errorf("%s has no version", h.url.Host)
+		}
+		got = append(got, h.url.Host)
+	}
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("found %v, want %v", got, want)
+	}
+	if int(probed.Load()) != len(targets) {
+		t.Errorf("onProbed called %d times, want %d", probed.Load(), len(targets))
+	}
+}
+
+func TestRunScanWorkerBound(t *testing.T) {
+	targets := make([]string, 40)
+	for i := range targets {
+		targets[i] = fmt.Sprintf("127.0.0.1:%d", 10000+i)
+	}
+
+	var inflight, peak atomic.Int64
+	probe := func(ctx context.Context, sp serverProfile) (string, error) {
+		n := inflight.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		inflight.Add(-1)
+		return "", errors.New("nobody home")
+	}
+
+	var probed atomic.Int64
+	for range runScan(context.Background(), targets, 4, probe, func() { probed.Add(1) }) {
+		t.Error("found a host that does not exist")
+	}
+	if peak.Load() > 4 {
+		t.Errorf("%d probes in flight, want at most 4", peak.Load())
+	}
+	if probed.Load() != int64(len(targets)) {
+		t.Errorf("onProbed called %d times, want %d", probed.Load(), len(targets))
+	}
+}
+
+func TestRunScanCancel(t *testing.T) {
+	targets := make([]string, 100)
+	for i := range targets {
+		targets[i] = fmt.Sprintf("127.0.0.1:%d", 20000+i)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{}, len(targets))
+	probe := func(ctx context.Context, sp serverProfile) (string, error) {
+		started <- struct{}{}
+		<-ctx.Done() // a probe that would hang forever
+		return "", ctx.Err()
+	}
+
+	var probed atomic.Int64
+	found := runScan(ctx, targets, 8, probe, func() { probed.Add(1) })
+	<-started
+	cancel()
+
+	select {
+	case _, ok := <-found:
+		if ok {
+			t.Error("found a host after cancelling")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("scan did not stop after cancelling")
+	}
+	if probed.Load() >= int64(len(targets)) {
+		t.Errorf("all %d targets probed despite cancelling", probed.Load())
+	}
+}
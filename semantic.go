@@ -0,0 +1,336 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ollama/ollama/api"
+)
+
+const (
+	semanticFile    = "semantic.json"
+	semanticBatch   = 16 // messages per embed request
+	semanticResults = 20
+)
+
+// One embedded message. The text is kept so the index still finds
+// messages of chats that were deleted since.
+type semanticEntry struct {
+	Hash    string    `json:"hash"`
+	Role    string    `json:"role"`
+	Content string    `json:"content"`
+	Model   string    `json:"model,omitempty"` // who wrote it, for answers
+	Added   time.Time `json:"added"`
+	Vector  []float32 `json:"vector"`
+}
+
+// Vectors of different embedding models dont mix, so the index
+// belongs to one model and starts over when the user picks another.
+type semanticIndex struct {
+	Model   string          `json:"model"`
+	Entries []semanticEntry `json:"entries"`
+
+	indexing bool // only one indexer at a time
+	again    bool // messages arrived while indexing
+}
+
+func messageHash(m chatMessage) string {
+	sum := sha256.Sum256([]byte(m.Role + "\x00" + m.Content))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (si *semanticIndex) has(hash string) bool {
+	return slices.ContainsFunc(si.Entries, func(e semanticEntry) bool { return e.Hash == hash })
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+type semanticHit struct {
+	entry semanticEntry
+	score float64
+}
+
+// best first, skip leaves out the message we are looking for similar ones of
+func (si *semanticIndex) search(vector []float32, skip string) []semanticHit {
+	var hits []semanticHit
+	for _, e := range si.Entries {
+		if e.Hash != skip {
+			hits = append(hits, semanticHit{e, cosineSimilarity(vector, e.Vector)})
+		}
+	}
+	slices.SortFunc(hits, func(a, b semanticHit) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return 0
+	})
+	return hits[:min(len(hits), semanticResults)]
+}
+
+func (g *gui) loadSemanticIndex() {
+	g.semantic = &semanticIndex{}
+	uri, err := storage.Child(g.a.Storage().RootURI(), semanticFile)
+	if err != nil {
+		return // no storage on this platform, nothing to load
+	}
+	r, err := storage.Reader(uri)
+	if err == nil {
+		err = json.NewDecoder(r).Decode(g.semantic)
+		r.Close()
+		if err != nil {
+			g.addStartfunc(func() { dialog.ShowError(fmt.Errorf("error loading semantic index: %w", err), g.w) })
+		}
+	}
+	g.addSavefunc(func() {
+		if g.semantic.Model == "" && len(g.semantic.Entries) == 0 {
+			return
+		}
+		w, err := storage.Writer(uri)
+		if err != nil {
+			fmt.Printf("failed to save semantic index: %s\n", err)
+			return
+		}
+		defer w.Close()
+		err = json.NewEncoder(w).Encode(g.semantic)
+		if err != nil {
+			fmt.Printf("failed to save semantic index: %s\n", err)
+		}
+	})
+	g.addStartfunc(g.indexHistory)
+}
+
+// Embeds whatever part of the history is not in the index yet.
+// Cheap to call after every answer, it only sends the new messages.
+func (g *gui) indexHistory() {
+	si := g.semantic
+	if si.Model == "" || g.lastserver == "" {
+		return // switched off
+	}
+	if si.indexing {
+		si.again = true
+		return
+	}
+
+	var todo []chatMessage
+	seen := map[string]bool{}
+	for _, m := range g.messages {
+		h := messageHash(m)
+		if strings.TrimSpace(m.Content) == "" || m.Error != "" || m.Incomplete || seen[h] || si.has(h) {
+			continue
+		}
+		seen[h] = true
+		todo = append(todo, m)
+	}
+	if len(todo) == 0 {
+		return
+	}
+
+	si.indexing = true
+	client := g.client
+	model := si.Model
+	go func() {
+		var err error
+		for batch := range slices.Chunk(todo, semanticBatch) {
+			var input []string
+			for _, m := range batch {
+				input = append(input, stripThinking(m.Content))
+			}
+			var resp *api.EmbedResponse
+			resp, err = client.Embed(context.Background(), &api.EmbedRequest{Model: model, Input: input})
+			if err == nil && len(resp.Embeddings) != len(batch) {
+				err = fmt.Errorf("asked for %d embeddings, got %d", len(batch), len(resp.Embeddings))
+			}
+			if err != nil {
+				break
+			}
+			fyne.DoAndWait(func() {
+				if si.Model != model {
+					return // the user switched models meanwhile
+				}
+				for i, m := range batch {
+					si.Entries = append(si.Entries, semanticEntry{
+						Hash:    messageHash(m),
+						Role:    m.Role,
+						Content: m.Content,
+						Model:   m.Model,
+						Added:   time.Now(),
+						Vector:  resp.Embeddings[i],
+					})
+				}
+			})
+		}
+		fyne.Do(func() {
+			si.indexing = false
+			if err != nil {
+				fmt.Printf("semantic index: %s\n", err)
+				return // retried with the next answer
+			}
+			if si.again {
+				si.again = false
+				g.indexHistory()
+			}
+		})
+	}()
+}
+
+// Search the index, or with similarTo set, start with its neighbours
+func (g *gui) semanticWindow(similarTo *chatMessage) {
+	w := g.a.NewWindow("Semantic Search")
+	client := g.client
+	si := g.semantic
+
+	status := widget.NewLabel("")
+	status.Importance = widget.LowImportance
+	updateStatus := func() {
+		if si.Model == "" {
+			status.SetText("Off, pick an embedding model")
+			return
+		}
+		status.SetText(fmt.Sprintf("%d messages indexed with %s", len(si.Entries), si.Model))
+	}
+	updateStatus()
+
+	// embedding models first, anything can embed in a pinch
+	var names []string
+	for name, m := range g.modelinfo {
+		if embeddingOnly(m, g.modelcaps[m.Digest]) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	names = append([]string{"Off"}, names...)
+	model := widget.NewSelectEntry(names)
+	model.PlaceHolder = "nomic-embed-text"
+	model.SetText(si.Model)
+	if si.Model == "" {
+		model.SetText("Off")
+	}
+	apply := widget.NewButton("Use", func() {
+		name := strings.TrimSpace(model.Text)
+		if name == "Off" {
+			name = ""
+		}
+		if name == si.Model {
+			return
+		}
+		si.Model, si.Entries = name, nil
+		updateStatus()
+		g.indexHistory()
+	})
+	reindex := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), func() {
+		si.Entries = nil
+		g.indexHistory()
+		updateStatus()
+	})
+
+	results := container.NewVBox()
+	query := widget.NewEntry()
+	query.PlaceHolder = "What was that about..."
+
+	var show func(hits []semanticHit)
+	similar := func(e semanticEntry) {
+		query.SetText("")
+		show(si.search(e.Vector, e.Hash))
+	}
+	show = func(hits []semanticHit) {
+		results.Objects = nil
+		if len(hits) == 0 {
+			results.Add(widget.NewLabel("Nothing found"))
+		}
+		for _, h := range hits {
+			who := "User"
+			if h.entry.Role != "user" {
+				who = cmp.Or(h.entry.Model, "Assistant")
+			}
+			title := widget.NewLabelWithStyle(fmt.Sprintf("%.0f%%  %s, %s", h.score*100, who, h.entry.Added.Format("2006-01-02")),
+				fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			text := []rune(strings.TrimSpace(stripThinking(h.entry.Content)))
+			snippet := widget.NewLabel(string(text[:min(len(text), 300)]))
+			snippet.Wrapping = fyne.TextWrapWord
+
+			buttons := container.NewHBox(widget.NewButton("Similar", func() { similar(h.entry) }))
+			// only the current chat can be scrolled to
+			if i := slices.IndexFunc(g.messages, func(m chatMessage) bool { return messageHash(m) == h.entry.Hash }); i >= 0 {
+				buttons.Add(widget.NewButton("Show", func() {
+					g.msgscroller.GoToSpecific(i)
+					g.w.RequestFocus()
+				}))
+			}
+			results.Add(container.NewVBox(container.NewBorder(nil, nil, nil, buttons, title), snippet, widget.NewSeparator()))
+		}
+		results.Refresh()
+	}
+
+	query.OnSubmitted = func(q string) {
+		if si.Model == "" || strings.TrimSpace(q) == "" {
+			return
+		}
+		embedmodel := si.Model
+		status.SetText("Searching...")
+		go func() {
+			resp, err := client.Embed(context.Background(), &api.EmbedRequest{Model: embedmodel, Input: q})
+			fyne.Do(func() {
+				updateStatus()
+				if err != nil {
+					dialog.ShowError(fmt.Errorf("embedding the query: %w", err), w)
+					return
+				}
+				if len(resp.Embeddings) > 0 {
+					show(si.search(resp.Embeddings[0], ""))
+				}
+			})
+		}()
+	}
+	if similarTo != nil {
+		i := slices.IndexFunc(si.Entries, func(e semanticEntry) bool { return e.Hash == messageHash(*similarTo) })
+		if i >= 0 {
+			similar(si.Entries[i])
+		} else {
+			status.SetText("That message is not indexed yet")
+		}
+	}
+
+	w.SetContent(container.NewBorder(
+		container.NewVBox(
+			container.NewBorder(nil, nil, widget.NewLabel("Embedding model"), container.NewHBox(apply, reindex), model),
+			status,
+			container.NewBorder(nil, nil, nil, widget.NewButtonWithIcon("", theme.SearchIcon(), func() { query.OnSubmitted(query.Text) }), query),
+		),
+		nil, nil, nil,
+		container.NewVScroll(results),
+	))
+	w.Resize(fyne.NewSize(520, 600))
+	w.Show()
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/format"
+)
+
+const serverPanelRefresh = 3 * time.Second
+
+type keepAliveChoice struct {
+	name string
+	d    time.Duration
+}
+
+var keepLoadedChoices = []keepAliveChoice{
+	{"5 minutes", 5 * time.Minute},
+	{"30 minutes", 30 * time.Minute},
+	{"1 hour", time.Hour},
+	{"4 hours", 4 * time.Hour},
+	{"Forever", -1},
+}
+
+// Load, refresh or unload (d == 0) a model without asking it anything
+func setKeepAlive(ctx context.Context, client *api.Client, model string, d time.Duration) error {
+	req := &api.GenerateRequest{
+		Model:     model,
+		KeepAlive: &api.Duration{Duration: d},
+	}
+	return client.Generate(ctx, req, func(api.GenerateResponse) error { return nil })
+}
+
+// how much of the model lives on the gpu
+func vramSplit(m api.ProcessModelResponse) string {
+	if m.Size <= 0 {
+		return ""
+	}
+	gpu := m.SizeVRAM * 100 / m.Size
+	switch gpu {
+	case 100:
+		return "100% GPU"
+	case 0:
+		return "100% CPU"
+	default:
+		return fmt.Sprintf("%d%%/%d%% CPU/GPU (%s VRAM)", 100-gpu, gpu, format.HumanBytes(m.SizeVRAM))
+	}
+}
+
+func (g *gui) serverPanel() {
+	if g.serverpanel != nil {
+		g.serverpanel.RequestFocus()
+		return
+	}
+
+	w := g.a.NewWindow("Server")
+	g.serverpanel = w
+	client := g.client
+	host := g.lastserver
+	ctx, cancel := context.WithCancel(context.Background())
+
+	version := widget.NewLabel("Version: ...")
+	status := widget.NewLabel("")
+	status.Importance = widget.LowImportance
+	rows := container.NewVBox()
+
+	// the expiry labels tick without rebuilding everything, so the
+	// keep loaded select does not close under the users finger
+	type runningRow struct {
+		expires *widget.Label
+		model   api.ProcessModelResponse
+	}
+	var current []runningRow
+	var refresh func()
+
+	action := func(what string, model string, d time.Duration) {
+		status.SetText(what + " " + model + "...")
+		go func() {
+			err := setKeepAlive(ctx, client, model, d)
+			fyne.Do(func() {
+				if err != nil && ctx.Err() == nil {
+					dialog.ShowError(fmt.Errorf("%s %s: %w", strings.ToLower(what), model, err), w)
+				}
+				status.SetText("")
+				go refresh()
+			})
+		}()
+	}
+
+	rebuild := func(running []api.ProcessModelResponse) {
+		current = current[:0]
+		rows.Objects = nil
+		if len(running) == 0 {
+			rows.Add(widget.NewLabel("No models loaded"))
+		}
+		for _, m := range running {
+			name := widget.NewLabelWithStyle(m.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			details := widget.NewLabel(fmt.Sprintf("%s, %s", format.HumanBytes(m.Size), vramSplit(m)))
+			details.Wrapping = fyne.TextWrapWord
+			expires := widget.NewLabel("Unloads: " + format.HumanTimeLower(m.ExpiresAt, "never"))
+
+			unload := widget.NewButtonWithIcon("Unload now", theme.CancelIcon(), func() { action("Unloading", m.Name, 0) })
+			var names []string
+			for _, c := range keepLoadedChoices {
+				names = append(names, c.name)
+			}
+			keep := widget.NewSelect(names, func(s string) {
+				i := slices.IndexFunc(keepLoadedChoices, func(c keepAliveChoice) bool { return c.name == s })
+				action("Keeping", m.Name, keepLoadedChoices[i].d)
+			})
+			keep.PlaceHolder = "Keep loaded for..."
+
+			rows.Add(container.NewVBox(name, details, expires, container.NewGridWithColumns(2, unload, keep), widget.NewSeparator()))
+			current = append(current, runningRow{expires: expires, model: m})
+		}
+		rows.Refresh()
+	}
+
+	refresh = func() {
+		v, verr := client.Version(ctx)
+		running, rerr := client.ListRunning(ctx)
+		if ctx.Err() != nil {
+			return // window is gone
+		}
+		fyne.Do(func() {
+			if verr != nil {
+				version.SetText(fmt.Sprintf("%s: %s", host, verr))
+			} else {
+				version.SetText(fmt.Sprintf("%s: Ollama %s", host, v))
+			}
+			if rerr != nil {
+				rows.Objects = []fyne.CanvasObject{widget.NewLabel(rerr.Error())}
+				rows.Refresh()
+				current = nil
+				return
+			}
+
+			// only rebuild when something actually changed
+			same := len(running.Models) == len(current)
+			for i := 0; same && i < len(current); i++ {
+				same = current[i].model.Name == running.Models[i].Name &&
+					current[i].model.ExpiresAt.Equal(running.Models[i].ExpiresAt) &&
+					current[i].model.SizeVRAM == running.Models[i].SizeVRAM
+			}
+			if !same || len(current) == 0 {
+				rebuild(running.Models)
+				return
+			}
+			for _, r := range current {
+				r.expires.SetText("Unloads: " + format.HumanTimeLower(r.model.ExpiresAt, "never"))
+			}
+		})
+	}
+
+	go func() {
+		ticker := time.NewTicker(serverPanelRefresh)
+		defer ticker.Stop()
+		for {
+			refresh()
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	okbutton := widget.NewButton("Ok", func() { w.Close() })
+	okbutton.Importance = widget.HighImportance
+
+	w.SetOnClosed(func() {
+		cancel()
+		g.serverpanel = nil
+	})
+	w.SetContent(container.NewBorder(
+		container.NewVBox(version, widget.NewLabelWithStyle("Loaded models", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})),
+		container.NewVBox(status, okbutton),
+		nil, nil,
+		container.NewVScroll(rows),
+	))
+	w.Resize(fyne.NewSize(440, 480))
+	w.Show()
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ollama/ollama/api"
+)
+
+const defaultKeepAlive = 30 * time.Minute
+
+// A server we know how to talk to
+type serverProfile struct {
+	Host      string         `json:"host"`
+	Proxy     string         `json:"proxy,omitempty"`     // http://, https://, socks5:// or socks5h://
+	KeepAlive *time.Duration `json:"keepalive,omitempty"` // nil is defaultKeepAlive
+}
+
+func (sp serverProfile) url() *url.URL {
+	return &url.URL{
+		Scheme: "http",
+		Host:   sp.Host,
+	}
+}
+
+func (sp serverProfile) client() *api.Client {
+	return api.NewClient(sp.url(), sp.httpClient(0))
+}
+
+// timeout 0 means no timeout, we stream after all
+func (sp serverProfile) httpClient(timeout time.Duration) *http.Client {
+	if sp.Proxy == "" && timeout == 0 {
+		return http.DefaultClient
+	}
+
+	// keep the environment proxy behaviour unless we have our own
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if sp.Proxy != "" {
+		proxy, err := parseProxy(sp.Proxy)
+		// a broken proxy must not silently turn into a direct connection
+		transport.Proxy = func(*http.Request) (*url.URL, error) { return proxy, err }
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+func parseProxy(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: needs http://, https://, socks5:// or socks5h://", s)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: no host", s)
+	}
+	return u, nil
+}
+
+func validateProxy(s string) error {
+	if s == "" {
+		return nil
+	}
+	_, err := parseProxy(s)
+	return err
+}
+
+// Remember the proxy for host. It does not make host a failover
+// server, but if it already is one its profile follows along.
+func (g *gui) setProxyFor(host, proxy string) {
+	if proxy == "" {
+		delete(g.proxies, host)
+	} else {
+		g.proxies[host] = proxy
+	}
+	i := slices.IndexFunc(g.servers, func(sp serverProfile) bool { return sp.Host == host })
+	if i >= 0 {
+		g.servers[i].Proxy = proxy
+	}
+}
+
+// the profile for host if the user made one, a plain one otherwise
+func (g *gui) profileFor(host string) serverProfile {
+	i := slices.IndexFunc(g.servers, func(sp serverProfile) bool { return sp.Host == host })
+	if i < 0 {
+		return serverProfile{Host: host, Proxy: g.proxies[host]}
+	}
+	return g.servers[i]
+}
+
+// Every proxy the user set up by host, a copy for goroutines
+func (g *gui) allProxies() map[string]string {
+	proxies := maps.Clone(g.proxies)
+	if proxies == nil {
+		proxies = map[string]string{}
+	}
+	for _, sp := range g.servers {
+		if sp.Proxy != "" {
+			proxies[sp.Host] = sp.Proxy
+		}
+	}
+	return proxies
+}
+
+// After the profile of the active server changed, so the next
+// request already goes the new way
+func (g *gui) reconnectActive() {
+	if g.lastserver != "" {
+		g.client = g.profileFor(g.lastserver).client()
+	}
+}
+
+func (g *gui) loadServers() {
+	s := g.a.Preferences().String("servers")
+	if len(s) > 0 {
+		err := json.Unmarshal([]byte(s), &g.servers)
+		if err != nil {
+			g.addStartfunc(func() { dialog.ShowError(fmt.Errorf("error loading server list: %w", err), g.w) })
+		}
+	}
+	g.proxies = map[string]string{}
+	s = g.a.Preferences().String("proxies")
+	if len(s) > 0 {
+		err := json.Unmarshal([]byte(s), &g.proxies)
+		if err != nil {
+			g.addStartfunc(func() { dialog.ShowError(fmt.Errorf("error loading proxies: %w", err), g.w) })
+		}
+	}
+	g.addSavefunc(func() {
+		b, err := json.Marshal(g.servers)
+		if err != nil {
+			fmt.Printf("failed to save server list: %s\n", err)
+			return
+		}
+		g.a.Preferences().SetString("servers", string(b))
+		b, err = json.Marshal(g.proxies)
+		if err != nil {
+			fmt.Printf("failed to save proxies: %s\n", err)
+			return
+		}
+		g.a.Preferences().SetString("proxies", string(b))
+	})
+}
+
+var errNoFailover = errors.New("no other server has the model")
+
+// Walks the user ordered server list and returns the first one that is
+// alive and has the model, along with the digest it has there. Servers
+// where the digest matches win over servers that just happen to have a
+// model with the same name.
+func findFailover(ctx context.Context, servers []serverProfile, tried map[string]bool, model, digest string) (serverProfile, *api.Client, string, error) {
+	var fallback *serverProfile
+	var fallbackdigest string
+	for _, sp := range servers {
+		if tried[sp.Host] {
+			continue
+		}
+
+		client := sp.client()
+		listCTX, cancel := context.WithTimeout(ctx, 5*time.Second)
+		list, err := client.List(listCTX)
+		cancel()
+		if err != nil {
+			continue // not healthy
+		}
+
+		for _, m := range list.Models {
+			if m.Name != model {
+				continue
+			}
+			if digest == "" || m.Digest == digest {
+				return sp, client, m.Digest, nil
+			}
+			if fallback == nil {
+				fallback, fallbackdigest = &sp, m.Digest
+			}
+		}
+	}
+
+	if fallback != nil {
+		return *fallback, fallback.client(), fallbackdigest, nil
+	}
+	return serverProfile{}, nil, "", errNoFailover
+}
+
+func (g *gui) serverListWidget() fyne.CanvasObject {
+	return widget.NewButton("Servers", func() {
+		w := g.a.NewWindow("Servers")
+
+		rows := container.NewVBox()
+		var rebuild func()
+		rebuild = func() {
+			rows.Objects = nil
+			for i := range g.servers {
+				host := widget.NewEntry()
+				host.PlaceHolder = "127.0.0.1:11434"
+				host.SetText(g.servers[i].Host)
+				host.OnChanged = func(s string) { g.servers[i].Host = s }
+
+				proxy := widget.NewEntry()
+				proxy.PlaceHolder = "no proxy, e.g. socks5://127.0.0.1:1080"
+				proxy.SetText(g.servers[i].Proxy)
+				proxy.Validator = validateProxy
+				proxy.OnChanged = func(s string) {
+					if validateProxy(s) != nil {
+						return // the validator shows what is wrong
+					}
+					if g.servers[i].Host == g.lastserver {
+						g.setProxyFor(g.lastserver, s)
+						g.reconnectActive()
+						return
+					}
+					g.servers[i].Proxy = s
+				}
+
+				keepalive := widget.NewSelect(keepAliveNames(), func(s string) { g.servers[i].KeepAlive = keepAliveFromName(s) })
+				keepalive.SetSelected(keepAliveName(g.servers[i].KeepAlive))
+
+				up := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {
+					if i > 0 {
+						g.servers[i-1], g.servers[i] = g.servers[i], g.servers[i-1]
+						rebuild()
+					}
+				})
+				down := widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() {
+					if i < len(g.servers)-1 {
+						g.servers[i+1], g.servers[i] = g.servers[i], g.servers[i+1]
+						rebuild()
+					}
+				})
+				del := widget.NewButtonWithIcon("", theme.DeleteIcon(), func() {
+					g.servers = slices.Delete(g.servers, i, i+1)
+					rebuild()
+				})
+				rows.Add(container.NewBorder(nil, nil, nil, container.NewHBox(up, down, del), container.NewVBox(host, proxy,
+					container.NewBorder(nil, nil, widget.NewLabel("Keep models loaded"), nil, keepalive))))
+				rows.Add(widget.NewSeparator())
+			}
+			rows.Refresh()
+		}
+		rebuild()
+
+		add := widget.NewButtonWithIcon("Add", theme.ContentAddIcon(), func() {
+			g.servers = append(g.servers, serverProfile{})
+			rebuild()
+		})
+		okbutton := widget.NewButton("Ok", func() { w.Close() })
+		okbutton.Importance = widget.HighImportance
+
+		w.SetOnClosed(func() {
+			// drop the rows the user left empty
+			g.servers = slices.DeleteFunc(g.servers, func(sp serverProfile) bool { return sp.Host == "" })
+			// the active server might have been edited, added or removed
+			g.reconnectActive()
+		})
+		w.SetContent(container.NewBorder(
+			widget.NewLabel("Tried from top to bottom when the active server fails"),
+			container.NewVBox(add, okbutton),
+			nil, nil,
+			container.NewVScroll(rows),
+		))
+		w.Resize(fyne.NewSize(440, 360))
+		w.Show()
+	})
+}
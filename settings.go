@@ -34,11 +34,15 @@ func (g *gui) helpWidget() fyne.CanvasObject {
 
 func helptext() fyne.CanvasObject {
 	s := "### Information\n"
-	s += "- Right click to copy Message\n"
+	s += "- Hover a Message and click \"...\" for its actions\n"
 	s += "- - Long Press on Mobile\n"
-	s += "- Double Click/Press to delete Message\n"
-	s += "- Normal Render one Click/Press to show\n"
-	s += "- - thinking if the model supports it\n"
+	s += "- Tap, Right Click and Double Click do what\n"
+	s += "- - you set in Gestures\n"
+	s += "- Deleted Messages and Chats stay in the Trash\n"
+	s += "- - until the Application closes\n"
+	s += "- The send button stops a running answer\n"
+	s += "- - cut off answers can be continued\n"
+	s += "- Pull, compare and manage models in the menu next to refresh\n"
 	s += "- Do not force close the Application\n"
 	s += "- Make your Ollama visible on LAN\n"
 	s += "- - `OLLAMA_HOST=\"http://0.0.0.0:11434\"`\n"
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// how long the undo snackbar stays
+const undoTime = 6 * time.Second
+
+// Something that got taken out of the history. The trash only lives
+// as long as the app does, it is not saved.
+type trashEntry struct {
+	id       int
+	deleted  time.Time
+	index    int // where a single message was
+	messages []chatMessage
+	history  bool // the whole conversation
+}
+
+// "Assistant message (llama3.2:3b)" or "Conversation, 12 messages"
+func (t trashEntry) title() string {
+	if t.history {
+		if len(t.messages) == 1 {
+			return "Conversation, 1 message"
+		}
+		return fmt.Sprintf("Conversation, %d messages", len(t.messages))
+	}
+	m := t.messages[0]
+	title := "User message"
+	if m.Role != "user" {
+		title = "Assistant message"
+	}
+	if label := modelLabel(m); label != "" {
+		title += " (" + label + ")"
+	}
+	return title
+}
+
+// the first line of the first message, for the trash list
+func (t trashEntry) preview() string {
+	for _, m := range t.messages {
+		s := strings.TrimSpace(stripThinking(m.Content))
+		if s == "" {
+			continue
+		}
+		s, _, _ = strings.Cut(s, "\n")
+		if len([]rune(s)) > 80 {
+			s = string([]rune(s)[:80]) + "..."
+		}
+		return s
+	}
+	return ""
+}
+
+type trashBin struct {
+	entries []trashEntry // oldest first
+	nextid  int
+	// the snackbar below the messages
+	snackbar *fyne.Container
+	snacktxt *widget.Label
+	snackid  int    // the entry the snackbar undoes
+	changed  func() // refreshes the trash window if open
+}
+
+func (g *gui) throwAway(e trashEntry) {
+	g.trash.nextid++
+	e.id = g.trash.nextid
+	e.deleted = time.Now()
+	g.trash.entries = append(g.trash.entries, e)
+	g.showUndo(e)
+	if g.trash.changed != nil {
+		g.trash.changed()
+	}
+}
+
+// Takes the message at index out of the history, undoable
+func (g *gui) deleteMessage(index int) {
+	if index >= len(g.messages) {
+		return
+	}
+	if g.stopGenerating != nil {
+		// the answer is written by index, moving things would break it
+		dialog.ShowInformation("Delete", "Wait for the answer to finish first.", g.w)
+		return
+	}
+	m := g.messages[index]
+	g.messages = slices.Delete(g.messages, index, index+1)
+	g.msgscroller.scroll.OnScrolled(fyne.Position{}) // redraw
+	g.throwAway(trashEntry{index: index, messages: []chatMessage{m}})
+}
+
+// Empties the history, undoable
+func (g *gui) clearHistory() {
+	if len(g.messages) == 0 {
+		return
+	}
+	old := g.messages
+	g.messages = []chatMessage{}
+	g.msgscroller.scroll.OnScrolled(fyne.Position{}) // redraw
+	g.throwAway(trashEntry{messages: old, history: true})
+}
+
+// Puts the entry with id back where it came from. A conversation
+// replaces the current one, which goes to the trash in turn.
+func (g *gui) restore(id int) {
+	i := slices.IndexFunc(g.trash.entries, func(e trashEntry) bool { return e.id == id })
+	if i < 0 {
+		return // emptied in the meantime
+	}
+	if g.stopGenerating != nil {
+		// the answer is written by index, moving things would break it
+		dialog.ShowInformation("Restore", "Wait for the answer to finish first.", g.w)
+		return
+	}
+	e := g.trash.entries[i]
+	g.trash.entries = slices.Delete(g.trash.entries, i, i+1)
+	g.hideUndo()
+
+	if e.history {
+		current := g.messages
+		g.messages = slices.Clone(e.messages)
+		if len(current) > 0 {
+			g.throwAway(trashEntry{messages: current, history: true})
+		}
+	} else {
+		g.messages = slices.Insert(g.messages, min(e.index, len(g.messages)), e.messages...)
+	}
+	if e.history {
+		g.msgscroller.GoToBottom()
+	} else {
+		g.msgscroller.GoToSpecific(min(e.index, len(g.messages)-1))
+	}
+	if g.trash.changed != nil {
+		g.trash.changed()
+	}
+}
+
+// The snackbar sits on top of the message list, hidden until something
+// is deleted. It is not a popup so the list stays usable below it.
+func (g *gui) snackbarOverlay() fyne.CanvasObject {
+	g.trash.snacktxt = widget.NewLabel("")
+	undo := widget.NewButtonWithIcon("Undo", theme.ContentUndoIcon(), func() { g.restore(g.trash.snackid) })
+	undo.Importance = widget.HighImportance
+	bg := canvas.NewRectangle(theme.Color(theme.ColorNameOverlayBackground))
+	bg.CornerRadius = theme.InputRadiusSize()
+	bg.StrokeColor = theme.Color(theme.ColorNameShadow)
+	bg.StrokeWidth = 1
+	g.trash.snackbar = container.NewStack(bg, container.NewPadded(container.NewHBox(g.trash.snacktxt, undo)))
+	g.trash.snackbar.Hide()
+	return container.NewVBox(layout.NewSpacer(), container.NewCenter(g.trash.snackbar))
+}
+
+func (g *gui) showUndo(e trashEntry) {
+	if g.trash.snackbar == nil {
+		return
+	}
+	g.trash.snackid = e.id
+	g.trash.snacktxt.SetText("Deleted " + strings.ToLower(e.title()[:1]) + e.title()[1:])
+	g.trash.snackbar.Show()
+	time.AfterFunc(undoTime, func() {
+		fyne.Do(func() {
+			if g.trash.snackid == e.id {
+				g.hideUndo()
+			}
+		})
+	})
+}
+
+func (g *gui) hideUndo() {
+	g.trash.snackid = 0
+	if g.trash.snackbar != nil {
+		g.trash.snackbar.Hide()
+	}
+}
+
+// Everything deleted this session, newest first
+func (g *gui) trashWindow() {
+	w := g.a.NewWindow("Trash")
+
+	newest := func(i int) trashEntry { return g.trash.entries[len(g.trash.entries)-1-i] }
+	list := widget.NewList(
+		func() int { return len(g.trash.entries) },
+		func() fyne.CanvasObject {
+			restore := widget.NewButtonWithIcon("Restore", theme.ContentUndoIcon(), nil)
+			return container.NewBorder(nil, nil, nil, restore, container.NewVBox(
+				widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+				widget.NewLabel(""),
+			))
+		},
+		func(id widget.ListItemID, o fyne.CanvasObject) {
+			e := newest(id)
+			row := o.(*fyne.Container)
+			texts := row.Objects[0].(*fyne.Container)
+			texts.Objects[0].(*widget.Label).SetText(e.title() + " - " + e.deleted.Format(time.TimeOnly))
+			preview := texts.Objects[1].(*widget.Label)
+			preview.SetText(e.preview())
+			preview.Truncation = fyne.TextTruncateEllipsis
+			row.Objects[1].(*widget.Button).OnTapped = func() { g.restore(e.id) }
+		},
+	)
+	empty := widget.NewLabel("Nothing deleted since the app started")
+	empty.Importance = widget.LowImportance
+
+	update := func() {
+		empty.Hidden = len(g.trash.entries) > 0
+		empty.Refresh()
+		list.Refresh()
+	}
+	g.trash.changed = update
+	update()
+	w.SetOnClosed(func() { g.trash.changed = nil })
+
+	emptytrash := widget.NewButtonWithIcon("Empty trash", theme.DeleteIcon(), func() {
+		dialog.ShowConfirm("Empty Trash", "This cannot be undone.", func(ok bool) {
+			if ok {
+				g.trash.entries = nil
+				g.hideUndo()
+				update()
+			}
+		}, w)
+	})
+	emptytrash.Importance = widget.DangerImportance
+
+	w.SetContent(container.NewBorder(
+		widget.NewLabel("Kept until the app closes"), emptytrash, nil, nil,
+		container.NewStack(list, container.NewCenter(empty)),
+	))
+	w.Resize(fyne.NewSize(480, 420))
+	w.Show()
+}
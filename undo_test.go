@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestTrashEntryTitle(t *testing.T) {
+	user := chatMessage{Message: api.Message{Role: "user", Content: "hi"}}
+	answer := chatMessage{Message: api.Message{Role: "assistant", Content: "hello"}, messageMeta: messageMeta{Model: "llama3.2:3b"}}
+	unknown := chatMessage{Message: api.Message{Role: "assistant"}}
+
+	for _, tc := range []struct {
+		name  string
+		entry trashEntry
+		want  string
+	}{
+		{"user message", trashEntry{messages: []chatMessage{user}}, "User message"},
+		{"answer", trashEntry{messages: []chatMessage{answer}}, "Assistant message (llama3.2:3b)"},
+		{"answer from an unknown model", trashEntry{messages: []chatMessage{unknown}}, "Assistant message"},
+		{"history of one", trashEntry{history: true, messages: []chatMessage{user}}, "Conversation, 1 message"},
+		{"history", trashEntry{history: true, messages: []chatMessage{user, answer}}, "Conversation, 2 messages"},
+	} {
+		if got := tc.entry.title(); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTrashEntryPreview(t *testing.T) {
+	msg := func(s string) chatMessage { return chatMessage{Message: api.Message{Role: "user", Content: s}} }
+
+	for _, tc := range []struct {
+		name string
+		in   []chatMessage
+		want string
+	}{
+		{"first line", []chatMessage{msg("  one\ntwo")}, "one"},
+		{"without the thinking", []chatMessage{msg("<think>hmm</think>\nanswer")}, "answer"},
+		{"skips empty messages", []chatMessage{msg(""), msg("second")}, "second"},
+		{"long lines are cut", []chatMessage{msg(strings.Repeat("ä", 100))}, strings.Repeat("ä", 80) + "..."},
+	} {
+		if got := (trashEntry{messages: tc.in}).preview(); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}